@@ -0,0 +1,261 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+/**
+ * Requests of users to join an account
+ */
+
+type createAccessRequestParams struct {
+	Message string `json:"message" validate:"max=1000"`
+}
+
+// AccessRequestCreate asks the admins of an account to let the user in
+// [POST]/accounts/{id}/access-requests
+func (a *API) AccessRequestCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	params := &createAccessRequestParams{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Access Request params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, false).err(); err != nil {
+		return err
+	}
+
+	if account.IsOwner(user.ID) || account.IsMember(user.ID) {
+		return unprocessableEntityError("You are already a member of this account").WithErrorCode(errCodeAlreadyMember)
+	}
+
+	var request *models.AccessRequest
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if _, terr = models.FindPendingAccessRequest(tx, account.ID, user.ID); terr == nil {
+			return conflictError("There is already a pending request for this account").WithErrorCode(errCodeRequestPending)
+		} else if !models.IsNotFoundError(terr) {
+			return internalServerError("Database error finding access request").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		if request, terr = models.NewAccessRequest(account.ID, user.ID, user.Email, params.Message); terr != nil {
+			return internalServerError("Database error creating access request").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if terr = tx.Create(request); terr != nil {
+			return internalServerError("Database error saving new access request").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), account.ID, user.ID, user.ID, models.AccessRequestedAction, map[string]interface{}{
+			"access_request_id": request.ID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, request)
+}
+
+// AccessRequestsGet returns the access requests of an account,
+// filtered by `?status=pending|approved|denied`
+// Permission: account-users-invite
+// [GET]/accounts/{id}/access-requests
+func (a *API) AccessRequestsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.AccessRequestPending, models.AccessRequestApproved, models.AccessRequestDenied:
+	default:
+		return badRequestError("Invalid status '%v'", status).WithErrorCode(errCodeInvalidQuery)
+	}
+
+	requests, err := models.FindAccessRequests(a.db, account.ID, status)
+	if err != nil {
+		return internalServerError("Database error finding access requests").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"access_requests": requests,
+	})
+}
+
+type approveAccessRequestParams struct {
+	RoleID uuid.UUID `json:"role_id" validate:"required"`
+}
+
+// AccessRequestApprove adds the requester as member with the chosen role
+// Permission: account-users-invite, account-role-update for roles granting more than the approver has
+// [POST]/accounts/{id}/access-requests/{requestId}/approve
+func (a *API) AccessRequestApprove(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	params := &approveAccessRequestParams{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Access Request params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, false).err(); err != nil {
+		return err
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	request, err := a.getPendingAccessRequest(r, account)
+	if err != nil {
+		return err
+	}
+
+	if account.IsMember(request.UserID) {
+		return conflictError("User is already a member of this account").WithErrorCode(errCodeAlreadyMember)
+	}
+
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		role, terr := models.FindRoleByAccountAndID(tx, account.ID, params.RoleID)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return unprocessableEntityError("Role does not belong to this account").WithErrorCode(errCodeUnknownRole)
+			}
+			return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if a.grantsMoreThan(r, account, user, role) && !a.hasPermission(r, account, user, "account-role-update") {
+			return unauthorizedError("The Role grants more than your own, you need `account-role-update` Permission to assign it").WithErrorCode(errCodeMissingPermission)
+		}
+
+		// the cached account might miss memberships created meanwhile
+		member, terr := models.ClearExpiredAccountUser(tx, account.ID, request.UserID)
+		if terr != nil {
+			return internalServerError("Database error finding member").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if member {
+			return conflictError("User is already a member of this account").WithErrorCode(errCodeAlreadyMember)
+		}
+
+		if terr = request.Approve(tx, params.RoleID, user.ID); terr != nil {
+			return internalServerError("Error approving access request").WithErrorCode(errCodeInternal).WithInternalError(terr)
+		}
+
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), account.ID, user.ID, request.UserID, models.AccessRequestApprovedAction, map[string]interface{}{
+			"access_request_id": request.ID,
+			"role_id":           params.RoleID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + account.ID.String())
+
+	// the membership exists even if the requester could not be notified
+	if merr := a.Mailer(ctx).AccessRequestApprovedMail(request, account); merr != nil {
+		logrus.WithError(merr).WithField("access_request_id", request.ID).Error("Error sending access request approved mail")
+	}
+
+	return sendJSON(w, http.StatusOK, request)
+}
+
+// AccessRequestDeny rejects a pending access request
+// Permission: account-users-invite
+// [POST]/accounts/{id}/access-requests/{requestId}/deny
+func (a *API) AccessRequestDeny(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	request, err := a.getPendingAccessRequest(r, account)
+	if err != nil {
+		return err
+	}
+
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := request.Deny(tx, user.ID); terr != nil {
+			return internalServerError("Error denying access request").WithErrorCode(errCodeInternal).WithInternalError(terr)
+		}
+
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), account.ID, user.ID, request.UserID, models.AccessRequestDeniedAction, map[string]interface{}{
+			"access_request_id": request.ID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if merr := a.Mailer(ctx).AccessRequestDeniedMail(request, account); merr != nil {
+		logrus.WithError(merr).WithField("access_request_id", request.ID).Error("Error sending access request denied mail")
+	}
+
+	return sendJSON(w, http.StatusOK, request)
+}
+
+func (a *API) getPendingAccessRequest(r *http.Request, account *models.Account) (*models.AccessRequest, error) {
+	requestID, err := uuid.FromString(chi.URLParam(r, "requestId"))
+	if err != nil {
+		return nil, badRequestError("Invalid Access Request ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	request, err := models.FindAccessRequestByAccountAndID(a.db, account.ID, requestID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(err.Error()).WithErrorCode(errCodeAccessRequestNotFound)
+		}
+		return nil, internalServerError("Database error finding access request").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	if !request.IsPending() {
+		return nil, conflictError("Access request has already been %v", request.Status).WithErrorCode(errCodeRequestDecided)
+	}
+	return request, nil
+}
@@ -1,9 +1,9 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 
+	identity "github.com/delivc/identity/models"
 	"github.com/delivc/team/models"
 	"github.com/delivc/team/storage"
 	"github.com/go-chi/chi/v4"
@@ -12,7 +12,7 @@ import (
 
 // accountCreateParams
 type accountCreateParams struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=255"`
 	Aud  string `json:"-"`
 }
 
@@ -22,11 +22,13 @@ func (a *API) AccountCreate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	instanceID := getInstanceID(ctx)
 	params := &accountCreateParams{}
-	jsonDecoder := json.NewDecoder(r.Body)
 	user := getUser(ctx)
-	err := jsonDecoder.Decode(params)
+	err := decodeParams(r, params)
 	if err != nil {
-		return badRequestError("Could not read params: %v", err)
+		return badRequestError("Could not read params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, false).err(); err != nil {
+		return err
 	}
 
 	params.Aud = a.requestAud(ctx, r)
@@ -37,7 +39,7 @@ func (a *API) AccountCreate(w http.ResponseWriter, r *http.Request) error {
 		var terr error
 		account, terr = models.NewAccount(instanceID, params.Name, params.Aud)
 		if terr != nil {
-			return internalServerError("Database error creating account").WithInternalError(err)
+			return internalServerError("Database error creating account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 		}
 
 		if account.OwnerIDs == nil {
@@ -49,32 +51,37 @@ func (a *API) AccountCreate(w http.ResponseWriter, r *http.Request) error {
 		err = conn.Transaction(func(tx *storage.Connection) error {
 			// create account
 			if txerr := tx.Create(account); txerr != nil {
-				return internalServerError("Database error saving new account").WithInternalError(txerr)
+				return internalServerError("Database error saving new account").WithErrorCode(errCodeDatabase).WithInternalError(txerr)
 			}
-			// create admin role
-			permissions, err := models.AllPermissions(tx)
-			if err != nil {
-				return err
+			// create the template roles of the audience
+			templates := a.config.RoleTemplates.ForAudience(params.Aud)
+			if len(templates) == 0 {
+				return internalServerError("No role templates configured for audience %v", params.Aud).WithErrorCode(errCodeInternal)
 			}
-
-			admin, err := models.NewRole(account.ID, "Admin")
-			if err != nil {
-				return err
+			roles, txerr := models.SyncRoleTemplates(tx, account.ID, templates)
+			if txerr != nil {
+				return internalServerError("Database error saving template roles").WithErrorCode(errCodeDatabase).WithInternalError(txerr)
 			}
-			admin.Permissions = permissions
 
-			if txerr := tx.Create(admin); txerr != nil {
-				return internalServerError("Database error saving new admin role").WithInternalError(txerr)
+			// the creator gets the owner role, which is the first one
+			// if no template is marked as such
+			owner := roles[0]
+			for i, template := range templates {
+				if template.Owner {
+					owner = roles[i]
+					break
+				}
 			}
 
 			// attach user to account
-			if txerr := models.AttachUserToAccount(tx, user.ID, account.ID, admin.ID); txerr != nil {
-				return internalServerError("Database error attaching user to account").WithInternalError(txerr)
+			if txerr := models.AttachUserToAccount(tx, user.ID, account.ID, owner.ID); txerr != nil {
+				return internalServerError("Database error attaching user to account").WithErrorCode(errCodeDatabase).WithInternalError(txerr)
 			}
 
-			// do we need more default roles?, ehehehe
-			// nope: this is related to the Owner of the Account
-			account.Roles = []models.Role{*admin}
+			account.Roles = []models.Role{}
+			for _, role := range roles {
+				account.Roles = append(account.Roles, *role)
+			}
 
 			return nil
 		})
@@ -99,7 +106,7 @@ func (a *API) AccountCreate(w http.ResponseWriter, r *http.Request) error {
 func (a *API) AccountDelete(w http.ResponseWriter, r *http.Request) error {
 	accountID, err := uuid.FromString(chi.URLParam(r, "id"))
 	if err != nil {
-		return badRequestError("Invalid Account ID")
+		return badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
 	}
 	ctx := r.Context()
 	user := getUser(ctx)
@@ -109,7 +116,7 @@ func (a *API) AccountDelete(w http.ResponseWriter, r *http.Request) error {
 		// we are not doing any more validation
 		// just kick'em off directly
 		if _, err := models.DeleteAccount(a.db, accountID); err != nil {
-			return internalServerError("Database error deleting account").WithInternalError(err)
+			return internalServerError("Database error deleting account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 		}
 	}
 	// if given user is not super admin
@@ -118,23 +125,35 @@ func (a *API) AccountDelete(w http.ResponseWriter, r *http.Request) error {
 	account, err := models.FindAccountByID(a.db, accountID)
 	if err != nil {
 		if models.IsNotFoundError(err) {
-			return notFoundError(err.Error())
+			return notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
 		}
-		return internalServerError("Database error finding account").WithInternalError(err)
+		return internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 	}
 
 	if !account.IsOwner(user.ID) {
-		return unauthorizedError("You dont have proper permission")
+		return unauthorizedError("You dont have proper permission").WithErrorCode(errCodeMissingPermission)
 	}
 
 	if _, err := models.DeleteAccount(a.db, accountID); err != nil {
-		return internalServerError("Database error deleting account").WithInternalError(err)
+		return internalServerError("Database error deleting account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 	}
 
 	return sendJSON(w, http.StatusOK, map[string]interface{}{})
 }
 
+// accountFilters whitelists the filters of the account list
+var accountFilters = map[string]filterRule{
+	"name":                             {Operators: []models.FilterOperator{models.Equals, models.Prefix, models.Contains}, Parse: filterString},
+	"aud":                              {Operators: []models.FilterOperator{models.Equals}, Parse: filterString},
+	"owner_id":                         {Operators: []models.FilterOperator{models.Equals}, Parse: filterUUID},
+	"member_id":                        {Operators: []models.FilterOperator{models.Equals}, Parse: filterUUID},
+	"billing_email":                    {Operators: []models.FilterOperator{models.Equals}, Parse: filterString},
+	models.CreatedAt:                   {Operators: []models.FilterOperator{models.GreaterOrEqual, models.LessOrEqual}, Parse: filterTime},
+	models.AccountMetaDataFilter + "*": {Operators: []models.FilterOperator{models.Equals}, Parse: filterString},
+}
+
 // AccountsGet returns a list of all related accounts
+// ?filter=field:operator:value narrows the list down, see accountFilters
 func (a *API) AccountsGet(w http.ResponseWriter, r *http.Request) error {
 	// what is our caching key?
 	// what are we receiving?
@@ -150,20 +169,49 @@ func (a *API) AccountsGet(w http.ResponseWriter, r *http.Request) error {
 	}
 	pageParams, err := paginate(r)
 	if err != nil {
-		return badRequestError("Bad Pagination Parameters: %v", err)
+		return badRequestError("Bad Pagination Parameters: %v", err).WithErrorCode(errCodeInvalidPage)
+	}
+
+	sortParams, err := sort(r, map[string]bool{models.CreatedAt: true, models.UpdatedAt: true, "name": true}, []models.SortField{models.SortField{Name: models.CreatedAt, Dir: models.Descending}})
+	if err != nil {
+		return badRequestError("Bad Sort Parameters: %v", err).WithErrorCode(errCodeInvalidSort)
+	}
+
+	filterParams, err := filter(r, accountFilters)
+	if err != nil {
+		return badRequestError("Bad Filter Parameters: %v", err).WithErrorCode(errCodeInvalidFilter)
 	}
 
-	sortParams, err := sort(r, map[string]bool{models.CreatedAt: true}, []models.SortField{models.SortField{Name: models.CreatedAt, Dir: models.Descending}})
+	view, err := parseAccountView(r)
 	if err != nil {
-		return badRequestError("Bad Sort Parameters: %v", err)
+		return badRequestError("Bad Fieldset Parameters: %v", err).WithErrorCode(errCodeInvalidFieldset)
+	}
+	includes := []string{models.AccountRoles}
+	if view != nil {
+		includes = view.Includes
 	}
 
-	accounts, err := models.FindAccounts(a.db, userID, pageParams, sortParams)
+	accounts, err := models.FindAccounts(a.db, userID, pageParams, sortParams, filterParams, includes)
 	if err != nil {
-		return internalServerError("Database error finding accounts").WithInternalError(err)
+		return internalServerError("Database error finding accounts").WithErrorCode(errCodeDatabase).WithInternalError(err)
 	}
 	addPaginationHeaders(w, r, pageParams)
 
+	if view != nil {
+		rendered := []map[string]interface{}{}
+		for _, account := range accounts {
+			data, err := view.render(account)
+			if err != nil {
+				return internalServerError("Error rendering account").WithErrorCode(errCodeInternal).WithInternalError(err)
+			}
+			rendered = append(rendered, data)
+		}
+		return sendJSON(w, http.StatusOK, map[string]interface{}{
+			"accounts": rendered,
+			"aud":      aud,
+		})
+	}
+
 	return sendJSON(w, http.StatusOK, map[string]interface{}{
 		"accounts": accounts,
 		"aud":      aud,
@@ -183,7 +231,16 @@ func (a *API) AccountGet(w http.ResponseWriter, r *http.Request) error {
 
 	accountID, err = uuid.FromString(chi.URLParam(r, "id"))
 	if err != nil {
-		return badRequestError("Invalid Account ID")
+		return badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	view, err := parseAccountView(r)
+	if err != nil {
+		return badRequestError("Bad Fieldset Parameters: %v", err).WithErrorCode(errCodeInvalidFieldset)
+	}
+	if view != nil {
+		// a partial account is loaded on its own and never cached
+		return a.sendAccountView(w, accountID, view, user)
 	}
 
 	fromCache, exists := a.cache.Get("account-" + accountID.String())
@@ -201,16 +258,16 @@ func (a *API) AccountGet(w http.ResponseWriter, r *http.Request) error {
 			// but this would give attackers an idea about the existence of this
 			// account
 
-			return notFoundError("Account not found")
+			return notFoundError("Account not found").WithErrorCode(errCodeAccountNotFound)
 		}
 	}
 
 	account, err = models.FindAccountByID(a.db, accountID)
 	if err != nil {
 		if models.IsNotFoundError(err) {
-			return notFoundError(err.Error())
+			return notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
 		}
-		return internalServerError("Database error finding account").WithInternalError(err)
+		return internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 	}
 
 	// cache it
@@ -219,14 +276,46 @@ func (a *API) AccountGet(w http.ResponseWriter, r *http.Request) error {
 	if user.IsSuperAdmin || account.IsOwner(user.ID) || account.IsMember(user.ID) {
 		return sendJSON(w, http.StatusOK, account)
 	}
-	return notFoundError("Account not found")
+	return notFoundError("Account not found").WithErrorCode(errCodeAccountNotFound)
+}
+
+// sendAccountView loads and sends only the requested parts of an account
+func (a *API) sendAccountView(w http.ResponseWriter, accountID uuid.UUID, view *accountView, user *identity.User) error {
+	account, err := models.FindAccountByIDWith(a.db, accountID, view.Includes)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
+		}
+		return internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	allowed := user.IsSuperAdmin || account.IsOwner(user.ID)
+	if !allowed && view.includes(models.AccountUsers) {
+		allowed = account.IsMember(user.ID)
+	} else if !allowed {
+		// members are not loaded, look up the membership on its own
+		if _, err = models.FindAccountUser(a.db, accountID, user.ID); err == nil {
+			allowed = true
+		} else if !models.IsNotFoundError(err) {
+			return internalServerError("Database error finding member").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+	}
+	if !allowed {
+		return notFoundError("Account not found").WithErrorCode(errCodeAccountNotFound)
+	}
+
+	rendered, err := view.render(account)
+	if err != nil {
+		return internalServerError("Error rendering account").WithErrorCode(errCodeInternal).WithInternalError(err)
+	}
+	return sendJSON(w, http.StatusOK, rendered)
 }
 
 type accountUpdateParams struct {
-	Name           string         `json:"name"`
-	BillingName    string         `json:"billing_name"`
-	BillingEmail   string         `json:"billing_email"`
-	BillingDetails string         `json:"billing_details"`
+	Name           string         `json:"name" validate:"max=255"`
+	BillingName    string         `json:"billing_name" validate:"max=255"`
+	BillingEmail   string         `json:"billing_email" validate:"max=254,email"`
+	BillingDetails string         `json:"billing_details" validate:"max=255"`
 	MetaData       models.JSONMap `json:"account_meta_data"`
 }
 
@@ -237,20 +326,22 @@ func (a *API) AccountsUpdate(w http.ResponseWriter, r *http.Request) error {
 	var err error
 	accountID, err = uuid.FromString(chi.URLParam(r, "id"))
 	if err != nil {
-		return badRequestError("Invalid Account ID")
+		return badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
 	}
 
 	ctx := r.Context()
 	params := &accountUpdateParams{}
-	jsonDecoder := json.NewDecoder(r.Body)
-	err = jsonDecoder.Decode(params)
+	err = decodeParams(r, params)
 	if err != nil {
-		return badRequestError("Could not read Account Update params: %v", err)
+		return badRequestError("Could not read Account Update params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, true).err(); err != nil {
+		return err
 	}
 
 	user := getUser(ctx)
 	if user == nil {
-		return internalServerError("Error finding user object")
+		return internalServerError("Error finding user object").WithErrorCode(errCodeInternal)
 	}
 
 	// get the account,check permissions
@@ -262,54 +353,50 @@ func (a *API) AccountsUpdate(w http.ResponseWriter, r *http.Request) error {
 			account, err = models.FindAccountByID(a.db, accountID)
 			if err != nil {
 				if models.IsNotFoundError(err) {
-					return notFoundError(err.Error())
+					return notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
 				}
-				return internalServerError("Database error finding account").WithInternalError(err)
+				return internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 			}
 		}
 	} else {
 		account, err = models.FindAccountByID(a.db, accountID)
 		if err != nil {
 			if models.IsNotFoundError(err) {
-				return notFoundError(err.Error())
+				return notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
 			}
-			return internalServerError("Database error finding account").WithInternalError(err)
+			return internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 		}
 	}
 	err = a.db.Transaction(func(tx *storage.Connection) error {
 		var terr error
 		// get permissions eg. hasPermission
-		if account.HasPermissionTo(tx, "account-edit", user.ID) || account.IsOwner(user.ID) || user.IsSuperAdmin {
+		if a.hasPermission(r, account, user, "account-edit") {
 			if params.Name != "" {
 				if terr = account.UpdateName(tx, params.Name); terr != nil {
-					return internalServerError("Error during name change").WithInternalError(terr)
+					return internalServerError("Error during name change").WithErrorCode(errCodeInternal).WithInternalError(terr)
 				}
 			}
 			if params.BillingName != "" {
 				if terr = account.UpdateBillingName(tx, params.BillingName); terr != nil {
-					return internalServerError("Error during billing name change").WithInternalError(terr)
+					return internalServerError("Error during billing name change").WithErrorCode(errCodeInternal).WithInternalError(terr)
 				}
 			}
-			if len(params.BillingEmail) > 254 || params.BillingEmail != "" {
-				if !emailRegex.MatchString(params.BillingEmail) {
-					return unprocessableEntityError("Email is Invalid")
-				}
-				// no further validation happens here
+			if params.BillingEmail != "" {
 				if terr = account.UpdateBillingEmail(tx, params.BillingEmail); terr != nil {
-					return internalServerError("Error during billing email change").WithInternalError(terr)
+					return internalServerError("Error during billing email change").WithErrorCode(errCodeInternal).WithInternalError(terr)
 				}
 			}
 			if params.BillingDetails != "" {
 				if terr = account.UpdateBillingDetails(tx, params.BillingDetails); terr != nil {
-					return internalServerError("Error during billing details change").WithInternalError(terr)
+					return internalServerError("Error during billing details change").WithErrorCode(errCodeInternal).WithInternalError(terr)
 				}
 			}
 			if params.MetaData != nil {
 				if !user.IsSuperAdmin {
-					return unauthorizedError("Updating account_meta_data requires admin privileges")
+					return unauthorizedError("Updating account_meta_data requires admin privileges").WithErrorCode(errCodeSuperAdminOnly)
 				}
 				if terr = account.UpdateAccountMetaData(tx, params.MetaData); terr != nil {
-					return internalServerError("Error updating user").WithInternalError(terr)
+					return internalServerError("Error updating user").WithErrorCode(errCodeInternal).WithInternalError(terr)
 				}
 			}
 
@@ -318,7 +405,7 @@ func (a *API) AccountsUpdate(w http.ResponseWriter, r *http.Request) error {
 			// and create a service by its own
 			return nil
 		}
-		return unauthorizedError("You dont have `account-edit` Permission, ask your Manager")
+		return unauthorizedError("You dont have `account-edit` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
 	})
 
 	if err != nil {
@@ -330,3 +417,142 @@ func (a *API) AccountsUpdate(w http.ResponseWriter, r *http.Request) error {
 
 	return sendJSON(w, http.StatusOK, account)
 }
+
+// accountDocument holds the attributes of an account a merge patch can change
+type accountDocument struct {
+	Name            string                 `json:"name" validate:"required,max=255"`
+	BillingName     string                 `json:"billing_name" validate:"max=255"`
+	BillingEmail    string                 `json:"billing_email" validate:"max=254,email"`
+	BillingDetails  string                 `json:"billing_details" validate:"max=255"`
+	AccountMetaData map[string]interface{} `json:"account_metadata"`
+}
+
+// AccountPatch applies a JSON merge patch to an account,
+// `null` clears an attribute and only changed columns are saved
+// Permission: account-edit
+// [PATCH]/accounts/{id}
+func (a *API) AccountPatch(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-edit") {
+		return unauthorizedError("You dont have `account-edit` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	patch, err := readMergePatch(r)
+	if err != nil {
+		return err
+	}
+	if err = checkPatchable(patch, "name", "billing_name", "billing_email", "billing_details", "account_metadata"); err != nil {
+		return err
+	}
+	if _, ok := patch["account_metadata"]; ok && !user.IsSuperAdmin {
+		return unauthorizedError("Updating account_metadata requires admin privileges").WithErrorCode(errCodeSuperAdminOnly)
+	}
+
+	current := accountDocument{
+		Name:            account.Name,
+		BillingName:     account.BillingName,
+		BillingEmail:    account.BillingEmail,
+		BillingDetails:  account.BillingDetails,
+		AccountMetaData: account.AccountMetaData,
+	}
+	document, err := toDocument(current)
+	if err != nil {
+		return internalServerError("Error reading account").WithErrorCode(errCodeInternal).WithInternalError(err)
+	}
+	patched := accountDocument{}
+	if err = fromDocument(mergePatch(document, patch), &patched); err != nil {
+		return unprocessableEntityError("Invalid merge patch: %v", err).WithErrorCode(errCodeInvalidPatch)
+	}
+
+	// validate the resulting account before anything is saved
+	if err = validateParams(&patched, false).err(); err != nil {
+		return err
+	}
+
+	columns := []string{}
+	if patched.Name != current.Name {
+		account.Name = patched.Name
+		columns = append(columns, "name")
+	}
+	if patched.BillingName != current.BillingName {
+		account.BillingName = patched.BillingName
+		columns = append(columns, "billing_name")
+	}
+	if patched.BillingEmail != current.BillingEmail {
+		account.BillingEmail = patched.BillingEmail
+		columns = append(columns, "billing_email")
+	}
+	if patched.BillingDetails != current.BillingDetails {
+		account.BillingDetails = patched.BillingDetails
+		columns = append(columns, "billing_details")
+	}
+	if _, ok := patch["account_metadata"]; ok {
+		account.AccountMetaData = patched.AccountMetaData
+		columns = append(columns, "raw_account_meta_data")
+	}
+
+	if len(columns) > 0 {
+		if err = a.db.UpdateOnly(account, append(columns, "updated_at")...); err != nil {
+			a.cache.Delete("account-" + account.ID.String())
+			return internalServerError("Error updating account").WithErrorCode(errCodeInternal).WithInternalError(err)
+		}
+	}
+
+	a.cache.SetDefault("account-"+account.ID.String(), account)
+
+	return sendJSON(w, http.StatusOK, account)
+}
+
+// AccountRolesSync re-syncs the template roles of an account,
+// missing roles get created and the permissions of existing ones are reset
+// [POST]/accounts/{id}/role/sync
+func (a *API) AccountRolesSync(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-role-update") {
+		return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	var roles []*models.Role
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if roles, terr = models.SyncRoleTemplates(tx, account.ID, a.config.RoleTemplates.ForAudience(account.Aud)); terr != nil {
+			return internalServerError("Database error syncing template roles").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		a.cache.Delete("role-" + role.ID.String())
+	}
+	a.cache.Delete("roles-" + account.ID.String())
+	a.cache.Delete("account-" + account.ID.String())
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"roles": roles,
+	})
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+)
+
+/**
+ * Moving accounts between environments
+ */
+
+// AccountExport returns a portable copy of an account
+// User MUST be SuperAdmin or Owner
+// [GET]/accounts/{id}/export
+func (a *API) AccountExport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !(user.IsSuperAdmin || account.IsOwner(user.ID)) {
+		return unauthorizedError("Only owners can export an account").WithErrorCode(errCodeOwnerRequired)
+	}
+
+	export, err := models.ExportAccount(a.db, account.ID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
+		}
+		return internalServerError("Database error exporting account").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\"account-"+account.ID.String()+".json\"")
+	return sendJSON(w, http.StatusOK, export)
+}
+
+// AccountImport recreates an exported account, `on_conflict` decides
+// what happens if the account exists already: fail, replace or copy
+// User MUST be SuperAdmin
+// [POST]/accounts/import?on_conflict={policy}
+func (a *API) AccountImport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !user.IsSuperAdmin {
+		return unauthorizedError("Only super admins can import accounts").WithErrorCode(errCodeSuperAdminOnly)
+	}
+
+	policy := r.URL.Query().Get("on_conflict")
+	switch policy {
+	case "":
+		policy = models.ImportFail
+	case models.ImportFail, models.ImportReplace, models.ImportCopy:
+	default:
+		return badRequestError("Unknown on_conflict '%v', only '%v', '%v' and '%v' allowed", policy, models.ImportFail, models.ImportReplace, models.ImportCopy).WithErrorCode(errCodeInvalidQuery)
+	}
+
+	export := &models.AccountExport{}
+	if err := decodeParams(r, export); err != nil {
+		return badRequestError("Could not read Account export: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+
+	var result *models.ImportResult
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		result, terr = models.ImportAccount(tx, getInstanceID(ctx), export, policy)
+		return terr
+	})
+	if err != nil {
+		switch e := err.(type) {
+		case models.ImportConflictError:
+			return conflictError("%v, set `on_conflict` to replace or copy it", e.Error()).WithErrorCode(errCodeAccountExists)
+		case models.ImportDataLossError:
+			return conflictError("%v, remove them first or set `on_conflict` to copy", e.Error()).WithErrorCode(errCodeImportDataLoss)
+		case models.InvalidImportError:
+			return unprocessableEntityError(e.Error()).WithErrorCode(errCodeInvalidImport)
+		}
+		return internalServerError("Database error importing account").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	a.cache.Delete("account-" + result.AccountID.String())
+	a.cache.Delete("roles-" + result.AccountID.String())
+
+	return sendJSON(w, http.StatusCreated, result)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/delivc/team/conf"
+	"github.com/delivc/team/mailer"
 	"github.com/delivc/team/storage"
 	"github.com/go-chi/chi/v4"
 	"github.com/gofrs/uuid"
@@ -31,17 +33,19 @@ const (
 
 // API is the main REST API
 type API struct {
-	handler http.Handler
-	cache   *gcache.Cache
-	db      *storage.Connection
-	config  *conf.GlobalConfiguration
-	version string
-	start   time.Time
+	handler  http.Handler
+	routes   *router
+	cache    *gcache.Cache
+	db       *storage.Connection
+	resolver TXTResolver
+	config   *conf.GlobalConfiguration
+	version  string
+	start    time.Time
 }
 
 // New creates a new API Instance
 func New(ctx context.Context, globalConfig *conf.GlobalConfiguration, db *storage.Connection, version string) *API {
-	api := &API{config: globalConfig, db: db, version: version, start: time.Now()}
+	api := &API{config: globalConfig, db: db, resolver: net.DefaultResolver, version: version, start: time.Now()}
 
 	// initialize new cache
 	// cached items are valid for 24hours
@@ -58,40 +62,144 @@ func New(ctx context.Context, globalConfig *conf.GlobalConfiguration, db *storag
 
 	r.Get("/health", api.HealthCheck)
 
-	r.Route("/", func(r *router) {
-		r.UseBypass(logger)
-		r.Use(api.requireAuthentication)
-
-		r.Get("/accounts", api.AccountsGet)
-		r.Post("/accounts", api.AccountCreate)
-		r.Get("/accounts/{id}", api.AccountGet)
-		r.Put("/accounts/{id}", api.AccountsUpdate)
-		r.Delete("/accounts/{id}", api.AccountDelete)
-
-		r.Get("/permissions", api.PermissionsGet)
-
-		r.Route("/accounts/{id}/role", func(r *router) {
-			// nested routes for roles
-			r.Get("/", api.RoleGet)
-			r.Get("/{roleId}", api.RoleGet)
-			r.Post("/", api.RoleCreate)
-			r.Delete("/{roleId}", api.RoleDestroy)
-			r.Put("/{roleId}", api.RoleUpdate)
+	for _, version := range apiVersions {
+		version := version
+		r.Route("/"+version, func(r *router) {
+			r.Use(withAPIVersion(version))
+			api.mountRoutes(r, logger)
 		})
+	}
+
+	// the unversioned paths stay until their sunset
+	r.Group(func(r *router) {
+		r.Use(api.deprecateLegacyPaths)
+		api.mountRoutes(r, logger)
 	})
 
 	corsHandler := cors.New(cors.Options{
 		AllowedOrigins:   []string{"https://app.delivc.com", "http://app.delivc.com:8081"},
-		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
 		AllowCredentials: true,
 	})
 
+	api.routes = r
 	api.handler = corsHandler.Handler(chi.ServerBaseContext(ctx, r))
 
 	return api
 }
 
+// mountRoutes registers the endpoints of the API, every version is mounted
+// with the same routes, handlers which differ between versions are `versioned`
+func (a *API) mountRoutes(r *router, logger func(http.Handler) http.Handler) {
+	r.Get("/openapi.json", a.OpenAPIGet)
+
+	r.Route("/hooks", func(r *router) {
+		r.UseBypass(logger)
+
+		r.Post("/identity", a.IdentityHook)
+	})
+
+	r.Route("/operator", func(r *router) {
+		r.UseBypass(logger)
+		r.Use(a.requireOperator)
+
+		r.Post("/permissions", a.PermissionsRegister)
+		r.Post("/permissions/{name}/deprecate", a.PermissionDeprecate)
+		r.Delete("/permissions/{name}", a.PermissionRetire)
+
+		r.Get("/users/{userId}", a.UserDataExport)
+		r.Delete("/users/{userId}", a.UserDataErase)
+	})
+
+	r.Route("/scim/v2", func(r *router) {
+		r.UseBypass(logger)
+		r.Use(a.requireSCIMToken)
+
+		r.Get("/Users", a.SCIMUsersGet)
+		r.Post("/Users", a.SCIMUserCreate)
+		r.Get("/Users/{userId}", a.SCIMUserGet)
+		r.Put("/Users/{userId}", a.SCIMUserReplace)
+		r.Patch("/Users/{userId}", a.SCIMUserPatch)
+		r.Delete("/Users/{userId}", a.SCIMUserDestroy)
+
+		r.Get("/Groups", a.SCIMGroupsGet)
+		r.Post("/Groups", a.SCIMGroupCreate)
+		r.Get("/Groups/{groupId}", a.SCIMGroupGet)
+		r.Put("/Groups/{groupId}", a.SCIMGroupReplace)
+		r.Patch("/Groups/{groupId}", a.SCIMGroupPatch)
+		r.Delete("/Groups/{groupId}", a.SCIMGroupDestroy)
+	})
+
+	r.Route("/", func(r *router) {
+		r.UseBypass(logger)
+		r.Use(a.requireAuthentication)
+
+		r.Get("/accounts", a.AccountsGet)
+		r.Post("/accounts", a.AccountCreate)
+		r.Post("/accounts/import", a.AccountImport)
+		r.Get("/accounts/{id}", a.AccountGet)
+		r.Put("/accounts/{id}", a.AccountsUpdate)
+		r.Patch("/accounts/{id}", a.AccountPatch)
+		r.Delete("/accounts/{id}", a.AccountDelete)
+
+		r.Get("/accounts/{id}/authorize", a.AccountAuthorize)
+		r.Get("/accounts/{id}/effective-permissions", a.EffectivePermissionsGet)
+		r.Post("/accounts/{id}/leave", a.AccountLeave)
+		r.Post("/accounts/{id}/accept", a.InvitationAccept)
+		r.Post("/accounts/{id}/batch", a.AccountBatch)
+		r.Get("/accounts/{id}/export", a.AccountExport)
+
+		r.Get("/permissions", a.PermissionsGet)
+
+		r.Get("/user/memberships", a.UserMembershipsGet)
+		r.Post("/user/domain-join", a.UserDomainJoin)
+
+		r.Route("/accounts/{id}/role", func(r *router) {
+			// nested routes for roles
+			r.Get("/", a.RoleGet)
+			r.Get("/{roleId}", a.RoleGet)
+			r.Post("/", a.RoleCreate)
+			r.Post("/sync", a.AccountRolesSync)
+			r.Delete("/{roleId}", a.RoleDestroy)
+			r.Put("/{roleId}", a.RoleUpdate)
+			r.Patch("/{roleId}", a.RolePatch)
+		})
+
+		r.Route("/accounts/{id}/users", func(r *router) {
+			// nested routes for members
+			r.Get("/", a.MembersGet)
+			r.Put("/{userId}", a.MemberUpdate)
+		})
+
+		r.Route("/accounts/{id}/assignments", func(r *router) {
+			// nested routes for resource scoped role assignments
+			r.Get("/", a.RoleAssignmentsGet)
+			r.Post("/", a.RoleAssignmentCreate)
+			r.Delete("/{assignmentId}", a.RoleAssignmentDestroy)
+		})
+
+		r.Post("/accounts/{id}/scim-token", a.SCIMTokenCreate)
+		r.Delete("/accounts/{id}/scim-token", a.SCIMTokenDestroy)
+
+		r.Route("/accounts/{id}/domains", func(r *router) {
+			// nested routes for claimed email domains
+			r.Get("/", a.DomainsGet)
+			r.Post("/", a.DomainCreate)
+			r.Post("/{domainId}/verify", a.DomainVerify)
+			r.Delete("/{domainId}", a.DomainDestroy)
+		})
+
+		r.Route("/accounts/{id}/access-requests", func(r *router) {
+			// nested routes for requests to join an account
+			r.Get("/", a.AccessRequestsGet)
+			r.Post("/", a.AccessRequestCreate)
+			r.Post("/{requestId}/approve", a.AccessRequestApprove)
+			r.Post("/{requestId}/deny", a.AccessRequestDeny)
+		})
+	})
+}
+
 // ListenAndServe starts the REST API
 func (a *API) ListenAndServe(hostAndPort string) {
 	log := logrus.WithField("component", "api")
@@ -102,6 +210,7 @@ func (a *API) ListenAndServe(hostAndPort string) {
 
 	done := make(chan struct{})
 	defer close(done)
+	go a.sweepExpiredAccess(log, done)
 	go func() {
 		waitForTermination(log, done)
 		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
@@ -168,6 +277,12 @@ func (a *API) requestAud(ctx context.Context, r *http.Request) string {
 	return "app.delivc.com"
 }
 
+// Mailer returns the mailer of the instance
+func (a *API) Mailer(ctx context.Context) mailer.Mailer {
+	config := a.getConfig(ctx)
+	return mailer.NewMailer(config)
+}
+
 func (a *API) getConfig(ctx context.Context) *conf.Configuration {
 	obj := ctx.Value(configKey)
 	if obj == nil {
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * Resource scoped role assignments
+ * eg. "Editor" for space:abc only
+ */
+
+// RoleAssignmentsGet returns all resource scoped role assignments of an account
+// Permission: account-role-update
+func (a *API) RoleAssignmentsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-role-update") {
+		return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	assignments, err := models.FindRoleAssignmentsByAccount(a.db, account.ID)
+	if err != nil {
+		return internalServerError("Database error finding role assignments").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"assignments": assignments,
+	})
+}
+
+type createRoleAssignmentRequest struct {
+	UserID    uuid.UUID  `json:"user_id" validate:"required"`
+	RoleID    uuid.UUID  `json:"role_id" validate:"required"`
+	Resource  string     `json:"resource" validate:"required,max=255"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// RoleAssignmentCreate assigns a role of the account to a member
+// for a single resource
+// Permission: account-role-update
+func (a *API) RoleAssignmentCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-role-update") {
+		return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	params := &createRoleAssignmentRequest{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Role Assignment params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, false).err(); err != nil {
+		return err
+	}
+
+	resource, err := models.ParseResource(params.Resource)
+	if err != nil {
+		return unprocessableEntityError("Invalid Resource: %v", err).WithErrorCode(errCodeInvalidResource)
+	}
+
+	if !account.IsMember(params.UserID) {
+		return unprocessableEntityError("User is not a member of this account").WithErrorCode(errCodeNotAMember)
+	}
+	if params.ExpiresAt != nil && !params.ExpiresAt.After(time.Now()) {
+		return unprocessableEntityError("expires_at must be in the future").WithErrorCode(errCodeInvalidExpiry).WithField("expires_at")
+	}
+
+	var assignment *models.RoleAssignment
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if _, terr = models.FindRoleByAccountAndID(tx, account.ID, params.RoleID); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return unprocessableEntityError("Role does not belong to this account").WithErrorCode(errCodeUnknownRole)
+			}
+			return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		if assignment, terr = models.NewRoleAssignment(account.ID, params.UserID, params.RoleID, *resource); terr != nil {
+			return internalServerError("Database error creating role assignment").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		assignment.ExpiresAt = params.ExpiresAt
+
+		if terr = tx.Create(assignment); terr != nil {
+			return internalServerError("Database error saving new role assignment").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, assignment)
+}
+
+// RoleAssignmentDestroy removes a resource scoped role assignment
+// Permission: account-role-update
+func (a *API) RoleAssignmentDestroy(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	assignmentID, err := uuid.FromString(chi.URLParam(r, "assignmentId"))
+	if err != nil {
+		return badRequestError("Invalid Role Assignment ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	if !a.hasPermission(r, account, user, "account-role-update") {
+		return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		assignment, terr := models.FindRoleAssignmentByAccountAndID(tx, account.ID, assignmentID)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(terr.Error()).WithErrorCode(errCodeAssignmentNotFound)
+			}
+			return internalServerError("Database error finding role assignment").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return models.DeleteRoleAssignment(tx, assignment.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"net/http"
 	"sync"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/delivc/identity/models"
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/gofrs/uuid"
 )
 
 // auth.go
@@ -57,6 +59,17 @@ func init() {
 	}()
 }
 
+// deleteUser drops all cached tokens of a user
+func (c *authCache) deleteUser(userID uuid.UUID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for token, item := range c.Items {
+		if item.User != nil && item.User.ID == userID {
+			delete(c.Items, token)
+		}
+	}
+}
+
 // requireAuthentication is a middleware to check if the user who made the
 // request is authenticated with our Identity Service
 func (a *API) requireAuthentication(w http.ResponseWriter, r *http.Request) (context.Context, error) {
@@ -68,6 +81,19 @@ func (a *API) requireAuthentication(w http.ResponseWriter, r *http.Request) (con
 	return a.validateToken(token, r, w)
 }
 
+// requireOperator is a middleware to check if the request
+// is made with the operator token of this instance
+func (a *API) requireOperator(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	token, err := a.extractBearerToken(w, r)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(a.config.OperatorToken)) != 1 {
+		return nil, unauthorizedError("Request does not include an operator token").WithErrorCode(errCodeTokenRequired)
+	}
+	return nil, nil
+}
+
 func (a *API) validateToken(bearer string, r *http.Request, w http.ResponseWriter) (context.Context, error) {
 	var user models.User
 	ctx := r.Context()
@@ -87,16 +113,16 @@ func (a *API) validateToken(bearer string, r *http.Request, w http.ResponseWrite
 	request.Header.Add("Authorization", "Bearer "+bearer)
 	resp, err := client.Do(request)
 	if err != nil {
-		return nil, unauthorizedError("Invalid token: %v", err)
+		return nil, unauthorizedError("Invalid token: %v", err).WithErrorCode(errCodeInvalidToken)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, unauthorizedError("Invalid token: token is expired")
+		return nil, unauthorizedError("Invalid token: token is expired").WithErrorCode(errCodeInvalidToken)
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
-		return nil, unauthorizedError("Invalid token: %v", err)
+		return nil, unauthorizedError("Invalid token: %v", err).WithErrorCode(errCodeInvalidToken)
 	}
 	claims := jwt.StandardClaims{}
 	p := jwt.Parser{
@@ -105,7 +131,7 @@ func (a *API) validateToken(bearer string, r *http.Request, w http.ResponseWrite
 	}
 	_, _, err = p.ParseUnverified(bearer, &claims)
 	if err != nil {
-		return nil, unauthorizedError("Invalid token: Token is does not match Schema")
+		return nil, unauthorizedError("Invalid token: Token is does not match Schema").WithErrorCode(errCodeInvalidToken)
 	}
 
 	cached = &authCacheItem{
@@ -117,18 +143,21 @@ func (a *API) validateToken(bearer string, r *http.Request, w http.ResponseWrite
 	cache.Items[bearer] = cached
 	cache.mutex.Unlock()
 
+	// only tokens which are not cached yet join accounts of their email domain
+	a.autoJoinAccounts(ctx, &user)
+
 	return withUser(ctx, &user), nil
 }
 
 func (a *API) extractBearerToken(w http.ResponseWriter, r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return "", unauthorizedError("This endpoint requires a Bearer token")
+		return "", unauthorizedError("This endpoint requires a Bearer token").WithErrorCode(errCodeTokenRequired)
 	}
 
 	matches := bearerRegexp.FindStringSubmatch(authHeader)
 	if len(matches) != 2 {
-		return "", unauthorizedError("This endpoint requires a Bearer token")
+		return "", unauthorizedError("This endpoint requires a Bearer token").WithErrorCode(errCodeTokenRequired)
 	}
 
 	return matches[1], nil
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	identity "github.com/delivc/identity/models"
+	"github.com/delivc/team/models"
+)
+
+// authorize decides if the user has a permission within the account.
+// Super admins and owners are allowed everything, deny rules of roles
+// win over grants of other roles
+func (a *API) authorize(r *http.Request, account *models.Account, user *identity.User, permission string, resource *models.Resource) models.Decision {
+	if user.IsSuperAdmin {
+		return models.Decision{Permission: permission, Allowed: true, Reason: models.ReasonSuperAdmin}
+	}
+	if account.IsOwner(user.ID) {
+		return models.Decision{Permission: permission, Allowed: true, Reason: models.ReasonOwner}
+	}
+	return account.Authorize(a.db, permission, user.ID, resource, a.accessContext(r))
+}
+
+// hasPermission checks if the user has an account wide permission for this request
+func (a *API) hasPermission(r *http.Request, account *models.Account, user *identity.User, permission string) bool {
+	return a.authorize(r, account, user, permission, nil).Allowed
+}
+
+// grantsMoreThan checks if the role grants a permission the user does not have,
+// assigning such a role needs more than the permission to invite
+func (a *API) grantsMoreThan(r *http.Request, account *models.Account, user *identity.User, role *models.Role) bool {
+	for _, permission := range role.Permissions {
+		if !a.hasPermission(r, account, user, permission.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessContext collects the request attributes conditions are evaluated against
+func (a *API) accessContext(r *http.Request) *models.AccessContext {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	return &models.AccessContext{
+		Time: time.Now(),
+		IP:   net.ParseIP(host),
+		Aud:  a.requestAud(r.Context(), r),
+	}
+}
+
+func resourceFromRequest(r *http.Request) (*models.Resource, error) {
+	value := r.URL.Query().Get("resource")
+	if value == "" {
+		return nil, nil
+	}
+	resource, err := models.ParseResource(value)
+	if err != nil {
+		return nil, badRequestError("Invalid Resource: %v", err).WithErrorCode(errCodeInvalidResource)
+	}
+	return resource, nil
+}
+
+// AccountAuthorize answers if the current user has a permission
+// within the account, optionally for a single resource
+// [GET]/accounts/{id}/authorize?permission=spaces-edit&resource=space:abc
+func (a *API) AccountAuthorize(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	permission := r.URL.Query().Get("permission")
+	if permission == "" {
+		return badRequestError("Missing permission").WithErrorCode(errCodeInvalidQuery)
+	}
+
+	resource, err := resourceFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	decision := a.authorize(r, account, user, permission, resource)
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"permission": permission,
+		"resource":   resource,
+		"allowed":    decision.Allowed,
+		"decision":   decision,
+	})
+}
+
+// EffectivePermissionsGet decides every registered permission for the current user
+// and explains each decision
+// [GET]/accounts/{id}/effective-permissions?resource=space:abc
+func (a *API) EffectivePermissionsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	resource, err := resourceFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	names, err := a.permissionNames()
+	if err != nil {
+		return err
+	}
+
+	var decisions []models.Decision
+	if user.IsSuperAdmin || account.IsOwner(user.ID) {
+		decisions = []models.Decision{}
+		for _, name := range names {
+			decisions = append(decisions, a.authorize(r, account, user, name, resource))
+		}
+	} else if decisions, err = account.EffectivePermissions(a.db, names, user.ID, resource, a.accessContext(r)); err != nil {
+		return internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"resource":    resource,
+		"permissions": decisions,
+	})
+}
+
+// permissionNames returns the names of all permissions a user can be asked for
+func (a *API) permissionNames() ([]string, error) {
+	permissions, err := models.AllPermissions(a.db)
+	if err != nil {
+		return nil, internalServerError("Database error finding permissions").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	names := []string{}
+	for _, permission := range permissions {
+		// wildcards are grants, not something one can be asked for
+		if !strings.HasSuffix(permission.Name, models.WildcardPermission) {
+			names = append(names, permission.Name)
+		}
+	}
+	return names, nil
+}
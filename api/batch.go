@@ -0,0 +1,311 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	identity "github.com/delivc/identity/models"
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * Many changes of an account in one transaction
+ */
+
+// Batch operations
+const (
+	batchCreateRole       = "create_role"
+	batchUpdateRole       = "update_role"
+	batchInviteMember     = "invite_member"
+	batchChangeMemberRole = "change_member_role"
+	batchRemoveMember     = "remove_member"
+)
+
+// maxBatchOperations limits the size of one batch
+const maxBatchOperations = 100
+
+// batchPermissions are required by the operations
+var batchPermissions = map[string]string{
+	batchCreateRole:       "account-role-create",
+	batchUpdateRole:       "account-role-update",
+	batchInviteMember:     "account-users-invite",
+	batchChangeMemberRole: "account-role-update",
+	batchRemoveMember:     "account-users-remove",
+}
+
+// batchOperation is one step of a batch, `ref` names a created role
+// so later operations can point to it with `role_ref`
+type batchOperation struct {
+	Op      string     `json:"op"`
+	Ref     string     `json:"ref"`
+	RoleID  *uuid.UUID `json:"role_id"`
+	RoleRef string     `json:"role_ref"`
+	UserID  uuid.UUID  `json:"user_id"`
+	createRoleRequest
+}
+
+type batchParams struct {
+	Operations []*batchOperation `json:"operations"`
+}
+
+type batchResult struct {
+	Index  int                 `json:"index"`
+	Op     string              `json:"op"`
+	Role   *models.Role        `json:"role,omitempty"`
+	Member *models.AccountUser `json:"member,omitempty"`
+}
+
+// batch runs the operations of one request and remembers created roles
+type batch struct {
+	tx      *storage.Connection
+	account *models.Account
+	user    *identity.User
+	refs    map[string]*models.Role
+	// changed roles are removed from the cache afterwards
+	roles []uuid.UUID
+}
+
+// AccountBatch runs a list of operations in order within one transaction,
+// either all of them are applied or none
+// [POST]/accounts/{id}/batch
+func (a *API) AccountBatch(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	params := &batchParams{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Batch params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if len(params.Operations) == 0 {
+		return unprocessableEntityError("Batch contains no operations").WithErrorCode(errCodeInvalidBatchLength)
+	}
+	if len(params.Operations) > maxBatchOperations {
+		return unprocessableEntityError("Batch contains more than %d operations", maxBatchOperations).WithErrorCode(errCodeInvalidBatchLength)
+	}
+
+	for i, operation := range params.Operations {
+		permission, ok := batchPermissions[operation.Op]
+		if !ok {
+			return unprocessableEntityError("Operation %d: unknown op '%v'", i, operation.Op).WithErrorCode(errCodeUnknownOperation)
+		}
+		if !a.hasPermission(r, account, user, permission) {
+			return unauthorizedError("Operation %d: you dont have `%v` Permission, ask your Manager", i, permission).WithErrorCode(errCodeMissingPermission)
+		}
+	}
+
+	results := []*batchResult{}
+	b := &batch{account: account, user: user, refs: map[string]*models.Role{}}
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		b.tx = tx
+		for i, operation := range params.Operations {
+			result, terr := b.run(operation)
+			if terr != nil {
+				return batchError(i, operation, terr)
+			}
+			result.Index = i
+			result.Op = operation.Op
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, roleID := range b.roles {
+		a.cache.Delete("role-" + roleID.String())
+	}
+	a.cache.Delete("roles-" + account.ID.String())
+	a.cache.Delete("account-" + account.ID.String())
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+	})
+}
+
+// batchError tells which operation failed, nothing of the batch is saved
+func batchError(index int, operation *batchOperation, err error) error {
+	if httpErr, ok := err.(*HTTPError); ok {
+		return &HTTPError{
+			Code:            httpErr.Code,
+			Message:         fmt.Sprintf("Operation %d (%v): %v", index, operation.Op, httpErr.Message),
+			ErrorCode:       httpErr.ErrorCode,
+			Errors:          httpErr.Errors,
+			InternalError:   httpErr.InternalError,
+			InternalMessage: httpErr.InternalMessage,
+		}
+	}
+	return internalServerError("Operation %d (%v) failed", index, operation.Op).WithErrorCode(errCodeInternal).WithInternalError(err)
+}
+
+func (b *batch) run(operation *batchOperation) (*batchResult, error) {
+	switch operation.Op {
+	case batchCreateRole:
+		return b.createRole(operation)
+	case batchUpdateRole:
+		return b.updateRole(operation)
+	case batchInviteMember:
+		return b.inviteMember(operation)
+	case batchChangeMemberRole:
+		return b.changeMemberRole(operation)
+	case batchRemoveMember:
+		return b.removeMember(operation)
+	}
+	return nil, unprocessableEntityError("Unknown op '%v'", operation.Op).WithErrorCode(errCodeUnknownOperation)
+}
+
+// role finds the role of an operation by `role_id` or `role_ref`
+func (b *batch) role(operation *batchOperation) (*models.Role, error) {
+	if operation.RoleRef != "" {
+		role, ok := b.refs[operation.RoleRef]
+		if !ok {
+			return nil, unprocessableEntityError("No role created with ref '%v'", operation.RoleRef).WithErrorCode(errCodeUnknownRef)
+		}
+		return role, nil
+	}
+	if operation.RoleID == nil {
+		return nil, unprocessableEntityError("role_id or role_ref is required").WithErrorCode(errCodeRequired).WithField("role_id")
+	}
+	role, err := models.FindRoleByAccountAndID(b.tx, b.account.ID, *operation.RoleID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, unprocessableEntityError("Role does not belong to this account").WithErrorCode(errCodeUnknownRole)
+		}
+		return nil, internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	return role, nil
+}
+
+func (b *batch) createRole(operation *batchOperation) (*batchResult, error) {
+	if operation.Ref != "" {
+		if _, exists := b.refs[operation.Ref]; exists {
+			return nil, unprocessableEntityError("Ref '%v' is used twice", operation.Ref).WithErrorCode(errCodeDuplicateRef)
+		}
+	}
+	if overlap := operation.overlap(); overlap != "" {
+		return nil, unprocessableEntityError("Permission %v can not be granted and denied at once", overlap).WithErrorCode(errCodeGrantedAndDenied).WithField("deny")
+	}
+	if err := operation.validateConditions(); err != nil {
+		return nil, err
+	}
+
+	role, err := createRole(b.tx, b.account.ID, &operation.createRoleRequest)
+	if err != nil {
+		return nil, err
+	}
+	if operation.Ref != "" {
+		b.refs[operation.Ref] = role
+	}
+	return &batchResult{Role: role}, nil
+}
+
+func (b *batch) updateRole(operation *batchOperation) (*batchResult, error) {
+	role, err := b.role(operation)
+	if err != nil {
+		return nil, err
+	}
+	if role.System {
+		return nil, forbiddenError("System roles can not be changed").WithErrorCode(errCodeSystemRole)
+	}
+
+	// compare against the stored permissions, if only one side changes
+	check := operation.createRoleRequest
+	if check.Permissions == nil {
+		check.Permissions = role.Permissions.Names()
+	}
+	if check.Deny == nil {
+		check.Deny = role.DeniedPermissions.Names()
+	}
+	if overlap := check.overlap(); overlap != "" {
+		return nil, unprocessableEntityError("Permission %v can not be granted and denied at once", overlap).WithErrorCode(errCodeGrantedAndDenied).WithField("deny")
+	}
+	if err = check.validateConditions(); err != nil {
+		return nil, err
+	}
+
+	if err = updateRole(b.tx, role, &operation.createRoleRequest); err != nil {
+		return nil, err
+	}
+	b.roles = append(b.roles, role.ID)
+	return &batchResult{Role: role}, nil
+}
+
+func (b *batch) inviteMember(operation *batchOperation) (*batchResult, error) {
+	if operation.UserID == uuid.Nil {
+		return nil, unprocessableEntityError("user_id is required").WithErrorCode(errCodeRequired).WithField("user_id")
+	}
+	role, err := b.role(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	// memberships which have run out are replaced like on approving access requests
+	exists, err := models.ClearExpiredAccountUser(b.tx, b.account.ID, operation.UserID)
+	if err != nil {
+		return nil, internalServerError("Database error finding member").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	if exists {
+		return nil, conflictError("User %v is already a member", operation.UserID).WithErrorCode(errCodeAlreadyMember)
+	}
+
+	member, err := models.InviteUserToAccount(b.tx, b.account.ID, operation.UserID, role.ID, b.user.ID)
+	if err != nil {
+		return nil, internalServerError("Database error inviting member").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	return &batchResult{Member: member}, nil
+}
+
+// member finds the active membership of the operation
+func (b *batch) member(operation *batchOperation) (*models.AccountUser, error) {
+	member, err := models.FindAccountUser(b.tx, b.account.ID, operation.UserID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError("User %v is no member", operation.UserID).WithErrorCode(errCodeMemberNotFound)
+		}
+		return nil, internalServerError("Database error finding member").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	return member, nil
+}
+
+func (b *batch) changeMemberRole(operation *batchOperation) (*batchResult, error) {
+	if operation.UserID == b.user.ID && !b.user.IsSuperAdmin {
+		return nil, forbiddenError("You can not change your own role").WithErrorCode(errCodeOwnRole)
+	}
+	member, err := b.member(operation)
+	if err != nil {
+		return nil, err
+	}
+	role, err := b.role(operation)
+	if err != nil {
+		return nil, err
+	}
+	if err = member.UpdateRole(b.tx, role.ID); err != nil {
+		return nil, internalServerError("Error updating role of member").WithErrorCode(errCodeInternal).WithInternalError(err)
+	}
+	return &batchResult{Member: member}, nil
+}
+
+func (b *batch) removeMember(operation *batchOperation) (*batchResult, error) {
+	if b.account.IsOwner(operation.UserID) {
+		return nil, unprocessableEntityError("Owners can not be removed").WithErrorCode(errCodeOwnerImmutable)
+	}
+	member, err := b.member(operation)
+	if err != nil {
+		return nil, err
+	}
+	if err = models.DeleteAccountUser(b.tx, b.account.ID, member.UserID); err != nil {
+		return nil, internalServerError("Database error removing member").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	return &batchResult{Member: member}, nil
+}
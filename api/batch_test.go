@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchParams(t *testing.T) {
+	params := &batchParams{}
+	require.NoError(t, json.Unmarshal([]byte(`{"operations": [
+		{"op": "create_role", "ref": "editors", "name": "Editors", "permissions": ["spaces-edit-content"]},
+		{"op": "invite_member", "user_id": "1dffa867-718b-4488-b07e-f838ef7b01e4", "role_ref": "editors"}
+	]}`), params))
+	require.Len(t, params.Operations, 2)
+	assert.Equal(t, "Editors", params.Operations[0].Name)
+	assert.Equal(t, []string{"spaces-edit-content"}, params.Operations[0].Permissions)
+	assert.Equal(t, "editors", params.Operations[1].RoleRef)
+	assert.Equal(t, "1dffa867-718b-4488-b07e-f838ef7b01e4", params.Operations[1].UserID.String())
+}
+
+func TestBatchError(t *testing.T) {
+	operation := &batchOperation{Op: batchRemoveMember}
+
+	err := batchError(2, operation, unprocessableEntityError("Owners can not be removed"))
+	httpErr, ok := err.(*HTTPError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusUnprocessableEntity, httpErr.Code)
+	assert.Equal(t, "Operation 2 (remove_member): Owners can not be removed", httpErr.Message)
+
+	err = batchError(0, operation, errors.New("connection lost"))
+	assert.Equal(t, http.StatusInternalServerError, err.(*HTTPError).Code)
+}
+
+func TestBatchPermissions(t *testing.T) {
+	// changing roles and removing members must not be possible with the invite permission alone
+	assert.Equal(t, "account-role-update", batchPermissions[batchChangeMemberRole])
+	assert.Equal(t, "account-users-remove", batchPermissions[batchRemoveMember])
+	for _, op := range []string{batchCreateRole, batchUpdateRole, batchInviteMember, batchChangeMemberRole, batchRemoveMember} {
+		assert.NotEmpty(t, batchPermissions[op], op)
+	}
+}
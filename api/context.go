@@ -2,9 +2,11 @@ package api
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/delivc/identity/models"
 	"github.com/delivc/team/conf"
+	teammodels "github.com/delivc/team/models"
 	"github.com/gofrs/uuid"
 )
 
@@ -20,6 +22,8 @@ const (
 	instanceIDKey = contextKey("instance_id")
 	instanceKey   = contextKey("instance")
 	requestIDKey  = contextKey("request_id")
+	scimTokenKey  = contextKey("scim_token")
+	apiVersionKey = contextKey("api_version")
 )
 
 // withUser adds the JWT token to the context.
@@ -79,3 +83,33 @@ func getInstanceID(ctx context.Context) uuid.UUID {
 	}
 	return obj.(uuid.UUID)
 }
+
+// withSCIMToken adds the SCIM token of the request to the context.
+func withSCIMToken(ctx context.Context, token *teammodels.SCIMToken) context.Context {
+	return context.WithValue(ctx, scimTokenKey, token)
+}
+
+// getSCIMToken reads the SCIM token from the context.
+func getSCIMToken(ctx context.Context) *teammodels.SCIMToken {
+	obj := ctx.Value(scimTokenKey)
+	if obj == nil {
+		return nil
+	}
+	return obj.(*teammodels.SCIMToken)
+}
+
+// withAPIVersion adds the version of the requested path to the context.
+func withAPIVersion(version string) middlewareHandler {
+	return func(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+		return context.WithValue(r.Context(), apiVersionKey, version), nil
+	}
+}
+
+// getAPIVersion reads the API version from the context.
+func getAPIVersion(ctx context.Context) string {
+	obj := ctx.Value(apiVersionKey)
+	if obj == nil {
+		return ""
+	}
+	return obj.(string)
+}
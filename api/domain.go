@@ -0,0 +1,335 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	identity "github.com/delivc/identity/models"
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+/**
+ * Email domains claimed by an account
+ * users with a confirmed email of a verified domain join automatically
+ */
+
+// TXTResolver looks up the DNS TXT records of a domain,
+// *net.Resolver satisfies it
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// verifyDomain checks the TXT records of the claimed domain for its verification token
+func verifyDomain(ctx context.Context, resolver TXTResolver, domain *models.AccountDomain) (bool, error) {
+	records, err := resolver.LookupTXT(ctx, domain.Domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return domain.MatchesTXT(records), nil
+}
+
+// DomainsGet returns the domains claimed by an account
+// Permission: account-users-invite
+// [GET]/accounts/{id}/domains
+func (a *API) DomainsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	domains, err := models.FindAccountDomains(a.db, account.ID)
+	if err != nil {
+		return internalServerError("Database error finding domains").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"domains": domains,
+	})
+}
+
+type createDomainParams struct {
+	Domain string    `json:"domain" validate:"required,max=255"`
+	RoleID uuid.UUID `json:"role_id" validate:"required"`
+}
+
+// DomainCreate claims a domain for an account, it has to be verified
+// with a TXT record before users join
+// Permission: account-users-invite and account-role-update, everyone with the domain gets the role
+// [POST]/accounts/{id}/domains
+func (a *API) DomainCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+	if !a.hasPermission(r, account, user, "account-role-update") {
+		return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	params := &createDomainParams{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Domain params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, false).err(); err != nil {
+		return err
+	}
+
+	if !emailRegex.MatchString("verify@" + models.NormalizeDomain(params.Domain)) {
+		return unprocessableEntityError("Invalid Domain '%v'", params.Domain).WithErrorCode(errCodeInvalidDomain).WithField("domain")
+	}
+
+	var domain *models.AccountDomain
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if _, terr = models.FindRoleByAccountAndID(tx, account.ID, params.RoleID); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return unprocessableEntityError("Role does not belong to this account").WithErrorCode(errCodeUnknownRole)
+			}
+			return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		domains, terr := models.FindAccountDomains(tx, account.ID)
+		if terr != nil {
+			return internalServerError("Database error finding domains").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		for _, existing := range domains {
+			if existing.Domain == models.NormalizeDomain(params.Domain) {
+				return conflictError("Domain '%v' is already claimed by this account", existing.Domain).WithErrorCode(errCodeDomainClaimed)
+			}
+		}
+
+		if domain, terr = models.NewAccountDomain(account.ID, params.RoleID, params.Domain); terr != nil {
+			return internalServerError("Database error creating domain").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if terr = tx.Create(domain); terr != nil {
+			return internalServerError("Database error saving new domain").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"domain":     domain,
+		"txt_record": domain.TXTRecord(),
+	})
+}
+
+// DomainVerify looks up the TXT record of a claimed domain
+// and marks the domain as verified if it matches
+// Permission: account-users-invite
+// [POST]/accounts/{id}/domains/{domainId}/verify
+func (a *API) DomainVerify(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	domain, err := a.getDomainFromRequest(r, account)
+	if err != nil {
+		return err
+	}
+	if domain.IsVerified() {
+		return sendJSON(w, http.StatusOK, domain)
+	}
+
+	verified, err := verifyDomain(ctx, a.resolver, domain)
+	if err != nil {
+		return internalServerError("Error looking up TXT records of '%v'", domain.Domain).WithErrorCode(errCodeInternal).WithInternalError(err)
+	}
+	if !verified {
+		return unprocessableEntityError("TXT record '%v' not found on '%v'", domain.TXTRecord(), domain.Domain).WithErrorCode(errCodeDomainNotVerified)
+	}
+
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		if terr := domain.Verify(tx); terr != nil {
+			return internalServerError("Error verifying domain").WithErrorCode(errCodeInternal).WithInternalError(terr)
+		}
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), account.ID, user.ID, uuid.Nil, models.DomainVerifiedAction, map[string]interface{}{
+			"domain": domain.Domain,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, domain)
+}
+
+// DomainDestroy removes a claimed domain, existing members stay
+// Permission: account-users-invite
+// [DELETE]/accounts/{id}/domains/{domainId}
+func (a *API) DomainDestroy(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	domain, err := a.getDomainFromRequest(r, account)
+	if err != nil {
+		return err
+	}
+
+	if err = models.DeleteAccountDomain(a.db, domain.ID); err != nil {
+		return internalServerError("Database error deleting domain").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+// UserDomainJoin joins the accounts which verified the email domain of the user
+// [POST]/user/domain-join
+func (a *API) UserDomainJoin(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	members, err := a.joinAccountsByDomain(ctx, user)
+	if err != nil {
+		return internalServerError("Error joining accounts of domain").WithErrorCode(errCodeInternal).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"joined": members,
+	})
+}
+
+// joinAccountsByDomain attaches the user to every account which verified
+// the domain of its confirmed email and returns the new memberships
+func (a *API) joinAccountsByDomain(ctx context.Context, user *identity.User) ([]*models.AccountUser, error) {
+	joined := []*models.AccountUser{}
+	if user.ConfirmedAt == nil {
+		return joined, nil
+	}
+	emailDomain := models.EmailDomain(user.Email)
+	if emailDomain == "" {
+		return joined, nil
+	}
+
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		domains, terr := models.FindVerifiedAccountDomains(tx, emailDomain)
+		if terr != nil {
+			return terr
+		}
+		for _, domain := range domains {
+			exists, terr := models.HasAccountUser(tx, domain.AccountID, user.ID)
+			if terr != nil {
+				return terr
+			}
+			if exists {
+				continue
+			}
+
+			member, terr := models.JoinAccountByDomain(tx, domain, user.ID)
+			if terr != nil {
+				return terr
+			}
+			if terr = models.NewAuditLogEntry(tx, getInstanceID(ctx), domain.AccountID, user.ID, user.ID, models.DomainJoinedAction, map[string]interface{}{
+				"domain":  domain.Domain,
+				"role_id": domain.RoleID,
+			}); terr != nil {
+				return terr
+			}
+			joined = append(joined, member)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range joined {
+		a.cache.Delete("account-" + member.AccountID.String())
+	}
+	return joined, nil
+}
+
+// autoJoinAccounts runs the domain join and links provisioned users
+// once a user is authenticated, failures must not block the request
+func (a *API) autoJoinAccounts(ctx context.Context, user *identity.User) {
+	members, err := a.joinAccountsByDomain(ctx, user)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Error("Error joining accounts of domain")
+	}
+	for _, member := range members {
+		logrus.WithFields(logrus.Fields{
+			"user_id":    user.ID,
+			"account_id": member.AccountID,
+		}).Info("User joined account by email domain")
+	}
+
+	if err := a.linkProvisionedUsers(ctx, user); err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Error("Error linking provisioned users")
+	}
+}
+
+func (a *API) getDomainFromRequest(r *http.Request, account *models.Account) (*models.AccountDomain, error) {
+	domainID, err := uuid.FromString(chi.URLParam(r, "domainId"))
+	if err != nil {
+		return nil, badRequestError("Invalid Domain ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	domain, err := models.FindAccountDomainByAccountAndID(a.db, account.ID, domainID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, notFoundError(err.Error()).WithErrorCode(errCodeDomainNotFound)
+		}
+		return nil, internalServerError("Database error finding domain").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	return domain, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubResolver map[string][]string
+
+func (s stubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := s[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	if records == nil {
+		return nil, errors.New("server misbehaving")
+	}
+	return records, nil
+}
+
+func TestVerifyDomain(t *testing.T) {
+	domain, err := models.NewAccountDomain(uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4()), "@Acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "acme.com", domain.Domain)
+
+	ctx := context.Background()
+
+	verified, err := verifyDomain(ctx, stubResolver{"acme.com": {"v=spf1 -all", domain.TXTRecord()}}, domain)
+	require.NoError(t, err)
+	assert.True(t, verified)
+
+	verified, err = verifyDomain(ctx, stubResolver{"acme.com": {"delivc-team-verification=other"}}, domain)
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	verified, err = verifyDomain(ctx, stubResolver{}, domain)
+	require.NoError(t, err)
+	assert.False(t, verified)
+
+	_, err = verifyDomain(ctx, stubResolver{"acme.com": nil}, domain)
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package api
+
+/**
+ * Machine readable error codes, clients can rely on them
+ * so they must not change once released
+ */
+
+// Malformed requests
+const (
+	errCodeInvalidBody      = "invalid_body"
+	errCodeInvalidQuery     = "invalid_query"
+	errCodeInvalidID        = "invalid_id"
+	errCodeInvalidUser      = "invalid_user"
+	errCodeInvalidResource  = "invalid_resource"
+	errCodeInvalidPage      = "invalid_pagination"
+	errCodeInvalidSort      = "invalid_sort"
+	errCodeInvalidFilter    = "invalid_filter"
+	errCodeInvalidFieldset  = "invalid_fieldset"
+	errCodeInvalidPatch     = "invalid_patch"
+	errCodeUnsupportedMedia = "unsupported_media_type"
+)
+
+// Authentication and authorization
+const (
+	errCodeTokenRequired     = "token_required"
+	errCodeInvalidToken      = "invalid_token"
+	errCodeInvalidSignature  = "invalid_signature"
+	errCodeMissingPermission = "missing_permission"
+	errCodeOwnerRequired     = "owner_required"
+	errCodeSuperAdminOnly    = "super_admin_required"
+	errCodeSystemRole        = "system_role"
+	errCodeOwnRole           = "own_role"
+)
+
+// Missing resources
+const (
+	errCodeAccountNotFound       = "account_not_found"
+	errCodeRoleNotFound          = "role_not_found"
+	errCodeMemberNotFound        = "member_not_found"
+	errCodePermissionNotFound    = "permission_not_found"
+	errCodeAssignmentNotFound    = "role_assignment_not_found"
+	errCodeDomainNotFound        = "domain_not_found"
+	errCodeAccessRequestNotFound = "access_request_not_found"
+	errCodeInvitationNotFound    = "invitation_not_found"
+	errCodeNotInVersion          = "not_in_version"
+	errCodeWebhookDisabled       = "webhook_not_configured"
+)
+
+// Conflicts with the current state
+const (
+	errCodeAlreadyMember  = "already_member"
+	errCodeRequestPending = "access_request_pending"
+	errCodeRequestDecided = "access_request_decided"
+	errCodeLastOwner      = "last_owner"
+	errCodeRoleInUse      = "role_in_use"
+	errCodeDomainClaimed  = "domain_claimed"
+	errCodeAccountExists  = "account_exists"
+	errCodeImportDataLoss = "import_data_loss"
+)
+
+// Invalid attributes
+const (
+	errCodeRequired           = "required"
+	errCodeInvalidEmail       = "invalid_email"
+	errCodeInvalidDomain      = "invalid_domain"
+	errCodeInvalidExpiry      = "invalid_expiry"
+	errCodeInvalidConditions  = "invalid_conditions"
+	errCodeInvalidImport      = "invalid_import"
+	errCodeReadOnly           = "read_only_attribute"
+	errCodeUnknownRole        = "unknown_role"
+	errCodeGrantedAndDenied   = "granted_and_denied"
+	errCodeNotAMember         = "not_a_member"
+	errCodeOwnerImmutable     = "owner_immutable"
+	errCodeSelfReassignment   = "self_reassignment"
+	errCodeDomainNotVerified  = "domain_not_verified"
+	errCodeUnknownOperation   = "unknown_operation"
+	errCodeUnknownRef         = "unknown_ref"
+	errCodeDuplicateRef       = "duplicate_ref"
+	errCodeInvalidBatchLength = "invalid_batch_length"
+	errCodeTooShort           = "too_short"
+	errCodeTooLong            = "too_long"
+	errCodeDuplicate          = "duplicate"
+	errCodeNameTaken          = "name_taken"
+	errCodeUnknownPermission  = "unknown_permission"
+	errCodeValidationFailed   = "validation_failed"
+)
+
+// Failures of the service
+const (
+	errCodeDatabase = "database_error"
+	errCodeInternal = "internal_error"
+)
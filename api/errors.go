@@ -28,17 +28,30 @@ func forbiddenError(fmtString string, args ...interface{}) *HTTPError {
 	return httpError(http.StatusForbidden, fmtString, args...)
 }
 
+func conflictError(fmtString string, args ...interface{}) *HTTPError {
+	return httpError(http.StatusConflict, fmtString, args...)
+}
+
 func unprocessableEntityError(fmtString string, args ...interface{}) *HTTPError {
 	return httpError(http.StatusUnprocessableEntity, fmtString, args...)
 }
 
 // HTTPError is an error with a message and an HTTP status code.
 type HTTPError struct {
-	Code            int    `json:"code"`
-	Message         string `json:"msg"`
-	InternalError   error  `json:"-"`
-	InternalMessage string `json:"-"`
-	ErrorID         string `json:"error_id,omitempty"`
+	Code            int          `json:"code"`
+	Message         string       `json:"msg"`
+	ErrorCode       string       `json:"error_code,omitempty"`
+	Errors          []FieldError `json:"errors,omitempty"`
+	InternalError   error        `json:"-"`
+	InternalMessage string       `json:"-"`
+	ErrorID         string       `json:"error_id,omitempty"`
+}
+
+// FieldError is an error of a single attribute of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 func (e *HTTPError) Error() string {
@@ -75,6 +88,19 @@ func (e *HTTPError) WithInternalError(err error) *HTTPError {
 	return e
 }
 
+// WithErrorCode sets the machine readable code of the error
+func (e *HTTPError) WithErrorCode(code string) *HTTPError {
+	e.ErrorCode = code
+	return e
+}
+
+// WithField tells which attribute of the request caused the error,
+// the field error takes the code and message of the error
+func (e *HTTPError) WithField(field string) *HTTPError {
+	e.Errors = append(e.Errors, FieldError{Field: field, Code: e.ErrorCode, Message: e.Message})
+	return e
+}
+
 // Recoverer is a middleware that recovers from panics, logs the panic (and a
 // backtrace), and returns a HTTP 500 (Internal Server Error) status if
 // possible. Recoverer prints a request ID if one is provided.
@@ -91,8 +117,9 @@ func recoverer(w http.ResponseWriter, r *http.Request) (context.Context, error)
 			}
 
 			se := &HTTPError{
-				Code:    http.StatusInternalServerError,
-				Message: http.StatusText(http.StatusInternalServerError),
+				Code:      http.StatusInternalServerError,
+				Message:   http.StatusText(http.StatusInternalServerError),
+				ErrorCode: errCodeInternal,
 			}
 			handleError(se, w, r)
 		}
@@ -118,14 +145,37 @@ func handleError(err error, w http.ResponseWriter, r *http.Request) {
 		} else {
 			log.WithError(e.Cause()).Info(e.Error())
 		}
+		if acceptsProblem(r) {
+			if jsonErr := sendProblem(w, r, e); jsonErr != nil {
+				handleError(jsonErr, w, r)
+			}
+			return
+		}
 		if jsonErr := sendJSON(w, e.Code, e); jsonErr != nil {
 			handleError(jsonErr, w, r)
 		}
+	case *SCIMError:
+		if e.Code >= http.StatusInternalServerError {
+			log.WithError(e.Cause()).Error(e.Error())
+		} else {
+			log.WithError(e.Cause()).Info(e.Error())
+		}
+		if jsonErr := sendSCIM(w, e.Code, e); jsonErr != nil {
+			handleError(jsonErr, w, r)
+		}
 	case ErrorCause:
 		handleError(e.Cause(), w, r)
 	default:
 		log.WithError(e).Errorf("Unhandled server error: %s", e.Error())
 		// hide real error details from response to prevent info leaks
+		if acceptsProblem(r) {
+			problem := internalServerError("Internal server error").WithErrorCode(errCodeInternal)
+			problem.ErrorID = errorID
+			if jsonErr := sendProblem(w, r, problem); jsonErr != nil {
+				log.WithError(jsonErr).Error("Error writing generic error message")
+			}
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		if _, writeErr := w.Write([]byte(`{"code":500,"msg":"Internal server error","error_id":"` + errorID + `"}`)); writeErr != nil {
 			log.WithError(writeErr).Error("Error writing generic error message")
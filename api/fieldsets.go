@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/delivc/team/models"
+)
+
+// accountFields whitelists the attributes of `?fields=`
+var accountFields = map[string]bool{
+	"id":                true,
+	"aud":               true,
+	"name":              true,
+	"billing_name":      true,
+	"billing_email":     true,
+	"billing_details":   true,
+	"billing_period":    true,
+	"payment_method_id": true,
+	"owner_ids":         true,
+	"account_metadata":  true,
+	"orphaned_at":       true,
+	"createdAt":         true,
+	"updatedAt":         true,
+}
+
+// accountIncludes maps `?include=` to the serialized keys of the associations
+var accountIncludes = map[string]string{
+	models.AccountRoles:           "roles",
+	models.AccountUsers:           "users",
+	models.AccountRolePermissions: "roles",
+}
+
+// accountView limits what is loaded and serialized of an account
+type accountView struct {
+	Fields   map[string]bool
+	Includes []string
+}
+
+func splitList(value string) []string {
+	values := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseAccountView reads `?fields=` and `?include=`,
+// nil means the full account as before
+func parseAccountView(r *http.Request) (*accountView, error) {
+	query := r.URL.Query()
+	_, hasFields := query["fields"]
+	_, hasIncludes := query["include"]
+	if !hasFields && !hasIncludes {
+		return nil, nil
+	}
+
+	view := &accountView{Fields: map[string]bool{}, Includes: []string{}}
+	for _, field := range splitList(query.Get("fields")) {
+		if !accountFields[field] {
+			return nil, fmt.Errorf("bad field '%v'", field)
+		}
+		view.Fields[field] = true
+	}
+	if len(view.Fields) == 0 {
+		for field := range accountFields {
+			view.Fields[field] = true
+		}
+	}
+	// the id identifies the account in every response
+	view.Fields["id"] = true
+
+	seen := map[string]bool{}
+	for _, include := range splitList(query.Get("include")) {
+		if _, ok := accountIncludes[include]; !ok {
+			return nil, fmt.Errorf("bad include '%v'", include)
+		}
+		if include == models.AccountRolePermissions && !seen[models.AccountRoles] {
+			seen[models.AccountRoles] = true
+			view.Includes = append(view.Includes, models.AccountRoles)
+		}
+		if !seen[include] {
+			seen[include] = true
+			view.Includes = append(view.Includes, include)
+		}
+	}
+	return view, nil
+}
+
+// includes reports if an association is requested
+func (v *accountView) includes(include string) bool {
+	for _, i := range v.Includes {
+		if i == include {
+			return true
+		}
+	}
+	return false
+}
+
+// render serializes the selected fields and associations of an account
+func (v *accountView) render(account *models.Account) (map[string]interface{}, error) {
+	data, err := json.Marshal(account)
+	if err != nil {
+		return nil, err
+	}
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	rendered := map[string]interface{}{}
+	for key, value := range all {
+		if v.Fields[key] {
+			rendered[key] = value
+		}
+	}
+	for _, include := range v.Includes {
+		key := accountIncludes[include]
+		if value, ok := all[key]; ok && value != nil {
+			rendered[key] = value
+		} else {
+			rendered[key] = []interface{}{}
+		}
+	}
+	return rendered, nil
+}
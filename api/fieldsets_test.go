@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountView(t *testing.T) {
+	view, err := parseAccountView(httptest.NewRequest("GET", "/accounts/1", nil))
+	require.NoError(t, err)
+	assert.Nil(t, view)
+
+	view, err = parseAccountView(httptest.NewRequest("GET", "/accounts/1?fields=name&include=roles.permissions", nil))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"id": true, "name": true}, view.Fields)
+	assert.Equal(t, []string{models.AccountRoles, models.AccountRolePermissions}, view.Includes)
+
+	account := &models.Account{ID: uuid.Must(uuid.NewV4()), Name: "Acme", Aud: "app.delivc.com"}
+	rendered, err := view.render(account)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":    account.ID.String(),
+		"name":  "Acme",
+		"roles": []interface{}{},
+	}, rendered)
+
+	view, err = parseAccountView(httptest.NewRequest("GET", "/accounts/1?include=users", nil))
+	require.NoError(t, err)
+	assert.True(t, view.Fields["aud"])
+	assert.True(t, view.includes(models.AccountUsers))
+	assert.False(t, view.includes(models.AccountRoles))
+
+	for _, bad := range []string{"fields=secret", "include=permissions"} {
+		_, err = parseAccountView(httptest.NewRequest("GET", "/accounts/1?"+bad, nil))
+		assert.Error(t, err, bad)
+	}
+}
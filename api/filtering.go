@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+)
+
+// filterWildcard allows any key below a field, e.g. `account_metadata.*`
+const filterWildcard = ".*"
+
+var filterKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// filterRule whitelists the operators of a field and parses its values
+type filterRule struct {
+	Operators []models.FilterOperator
+	Parse     func(value string) (interface{}, error)
+}
+
+func (f filterRule) allows(op models.FilterOperator) bool {
+	for _, allowed := range f.Operators {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+func filterString(value string) (interface{}, error) {
+	if value == "" {
+		return nil, fmt.Errorf("empty value")
+	}
+	return value, nil
+}
+
+func filterUUID(value string) (interface{}, error) {
+	return uuid.FromString(value)
+}
+
+// filterTime accepts RFC 3339 timestamps and plain dates
+func filterTime(value string) (interface{}, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// lookupFilterRule finds the rule of a field, keys below a wildcard field
+// have to be plain identifiers
+func lookupFilterRule(allowedFields map[string]filterRule, field string) (filterRule, bool) {
+	if rule, ok := allowedFields[field]; ok {
+		return rule, true
+	}
+	for name, rule := range allowedFields {
+		if !strings.HasSuffix(name, filterWildcard) {
+			continue
+		}
+		prefix := strings.TrimSuffix(name, "*")
+		if strings.HasPrefix(field, prefix) && filterKeyRegex.MatchString(strings.TrimPrefix(field, prefix)) {
+			return rule, true
+		}
+	}
+	return filterRule{}, false
+}
+
+func filter(r *http.Request, allowedFields map[string]filterRule) (*models.FilterParams, error) {
+	filterParams := &models.FilterParams{
+		Fields: []models.FilterField{},
+	}
+	for _, value := range r.URL.Query()["filter"] {
+		parts := strings.SplitN(value, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("bad filter '%v', expected 'field:operator:value'", value)
+		}
+		field, op := parts[0], models.FilterOperator(strings.ToLower(parts[1]))
+
+		rule, ok := lookupFilterRule(allowedFields, field)
+		if !ok {
+			return nil, fmt.Errorf("bad field for filter '%v'", field)
+		}
+		if !rule.allows(op) {
+			return nil, fmt.Errorf("bad operator for filter '%v', only %v allowed", field, rule.Operators)
+		}
+		parsed, err := rule.Parse(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("bad value for filter '%v': %v", field, err)
+		}
+		filterParams.Fields = append(filterParams.Fields, models.FilterField{Name: field, Op: op, Value: parsed})
+	}
+
+	return filterParams, nil
+}
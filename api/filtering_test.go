@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	ownerID := uuid.Must(uuid.NewV4())
+	query := url.Values{"filter": {
+		"name:prefix:Acme",
+		"owner_id:eq:" + ownerID.String(),
+		"created_at:gte:2020-03-01T10:00:00Z",
+		"account_metadata.plan:eq:pro",
+	}}
+	r := httptest.NewRequest("GET", "/accounts?"+query.Encode(), nil)
+
+	params, err := filter(r, accountFilters)
+	require.NoError(t, err)
+	require.Len(t, params.Fields, 4)
+	assert.Equal(t, models.FilterField{Name: "name", Op: models.Prefix, Value: "Acme"}, params.Fields[0])
+	assert.Equal(t, ownerID, params.Fields[1].Value)
+	assert.Equal(t, time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC), params.Fields[2].Value)
+	assert.Equal(t, models.FilterField{Name: "account_metadata.plan", Op: models.Equals, Value: "pro"}, params.Fields[3])
+
+	for _, bad := range []string{
+		"name",
+		"billing_name:eq:Acme",
+		"aud:contains:app",
+		"owner_id:eq:nope",
+		"created_at:gte:yesterday",
+		"account_metadata.a.b:eq:pro",
+		"account_metadata.:eq:pro",
+	} {
+		r = httptest.NewRequest("GET", "/accounts?"+url.Values{"filter": {bad}}.Encode(), nil)
+		_, err = filter(r, accountFilters)
+		assert.Error(t, err, bad)
+	}
+}
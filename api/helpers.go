@@ -51,29 +51,29 @@ func (a *API) getAccountFromRequest(r *http.Request) (*models.Account, error) {
 
 	accountID, err = uuid.FromString(chi.URLParam(r, "id"))
 	if err != nil {
-		return account, badRequestError("Invalid Account ID")
+		return account, badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
 	}
 
 	fromCache, exists := a.cache.Get("account-" + accountID.String())
 	if exists {
 		var ok bool
-		account, ok := fromCache.(*models.Account)
+		account, ok = fromCache.(*models.Account)
 		if !ok {
 			account, err = models.FindAccountByID(a.db, accountID)
 			if err != nil {
 				if models.IsNotFoundError(err) {
-					return account, notFoundError(err.Error())
+					return account, notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
 				}
-				return account, internalServerError("Database error finding account").WithInternalError(err)
+				return account, internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 			}
 		}
 	} else {
 		account, err = models.FindAccountByID(a.db, accountID)
 		if err != nil {
 			if models.IsNotFoundError(err) {
-				return account, notFoundError(err.Error())
+				return account, notFoundError(err.Error()).WithErrorCode(errCodeAccountNotFound)
 			}
-			return account, internalServerError("Database error finding account").WithInternalError(err)
+			return account, internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(err)
 		}
 	}
 
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	identity "github.com/delivc/identity/models"
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+/**
+ * User lifecycle events of identity
+ */
+
+const (
+	webhookSignatureHeader = "X-Webhook-Signature"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookTolerance       = 5 * time.Minute
+
+	userDeletedEvent = "user.deleted"
+	userUpdatedEvent = "user.updated"
+)
+
+type identityEvent struct {
+	Event string         `json:"event"`
+	User  *identity.User `json:"user"`
+	// Disabled users of an update event are removed like deleted ones
+	Disabled bool `json:"disabled"`
+}
+
+// signWebhook returns the signature of a webhook body,
+// a hex encoded HMAC-SHA256 of `{timestamp}.{body}`
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhook checks the signature of a webhook
+// and rejects webhooks outside of the tolerance
+func verifyWebhook(secret, timestamp, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	sent := time.Unix(seconds, 0)
+	if sent.Before(now.Add(-webhookTolerance)) || sent.After(now.Add(webhookTolerance)) {
+		return errors.New("timestamp is outside of the tolerance")
+	}
+	if !hmac.Equal([]byte(signWebhook(secret, timestamp, body)), []byte(strings.TrimSpace(signature))) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// IdentityHook handles the user lifecycle events of identity.
+// Deleted and disabled users are removed from all accounts
+// [POST]/hooks/identity
+func (a *API) IdentityHook(w http.ResponseWriter, r *http.Request) error {
+	if a.config.IdentityWebhookSecret == "" {
+		return notFoundError("Identity webhook is not configured").WithErrorCode(errCodeWebhookDisabled)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return badRequestError("Could not read webhook: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = verifyWebhook(a.config.IdentityWebhookSecret, r.Header.Get(webhookTimestampHeader), r.Header.Get(webhookSignatureHeader), body, time.Now()); err != nil {
+		return unauthorizedError("Invalid webhook signature").WithErrorCode(errCodeInvalidSignature).WithInternalError(err)
+	}
+
+	event := &identityEvent{}
+	if err = json.Unmarshal(body, event); err != nil {
+		return badRequestError("Could not read webhook: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if event.User == nil || event.User.ID == uuid.Nil {
+		return badRequestError("Webhook does not contain a user").WithErrorCode(errCodeInvalidBody)
+	}
+
+	// identity data of the user changed, the next request fetches it again
+	cache.deleteUser(event.User.ID)
+
+	removals := []*models.AccountRemoval{}
+	switch event.Event {
+	case userDeletedEvent:
+		removals, err = a.removeUser(r.Context(), event.User.ID, uuid.Nil, false)
+	case userUpdatedEvent:
+		if event.Disabled {
+			removals, err = a.removeUser(r.Context(), event.User.ID, uuid.Nil, false)
+		}
+	default:
+		return badRequestError("Unknown event '%v'", event.Event).WithErrorCode(errCodeInvalidBody)
+	}
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"accounts": removals,
+	})
+}
+
+// removeUser removes a user from all accounts and records the changes,
+// sole owners are replaced by the longest standing Admin member.
+// erase anonymizes all other rows referring to the user as well
+func (a *API) removeUser(ctx context.Context, userID, actorID uuid.UUID, erase bool) ([]*models.AccountRemoval, error) {
+	var removals []*models.AccountRemoval
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if erase {
+			// provisioned users are only found while they are linked
+			if terr = models.EraseSCIMUsers(tx, userID); terr != nil {
+				return internalServerError("Database error erasing provisioned users").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+		}
+		if removals, terr = models.RemoveUser(tx, userID); terr != nil {
+			return internalServerError("Database error removing user").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		instanceID := getInstanceID(ctx)
+		for _, removal := range removals {
+			if terr = models.NewAuditLogEntry(tx, instanceID, removal.AccountID, actorID, userID, models.UserRemovedAction, map[string]interface{}{
+				"was_member": removal.WasMember,
+				"was_owner":  removal.WasOwner,
+			}); terr != nil {
+				return internalServerError("Database error saving audit log entry").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+			if removal.NewOwnerID != nil {
+				terr = models.NewAuditLogEntry(tx, instanceID, removal.AccountID, actorID, *removal.NewOwnerID, models.OwnershipTransferredAction, map[string]interface{}{
+					"previous_owner_id": userID,
+				})
+			}
+			if removal.Orphaned {
+				terr = models.NewAuditLogEntry(tx, instanceID, removal.AccountID, actorID, userID, models.AccountOrphanedAction, nil)
+			}
+			if terr != nil {
+				return internalServerError("Database error saving audit log entry").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+		}
+
+		if !erase {
+			return nil
+		}
+		if terr = models.EraseUserData(tx, userID); terr != nil {
+			return internalServerError("Database error erasing user").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		// the erasure itself is recorded without the user
+		for _, removal := range removals {
+			if terr = models.NewAuditLogEntry(tx, instanceID, removal.AccountID, actorID, uuid.Nil, models.UserErasedAction, nil); terr != nil {
+				return internalServerError("Database error saving audit log entry").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cache.deleteUser(userID)
+	for _, removal := range removals {
+		a.cache.Delete("account-" + removal.AccountID.String())
+	}
+	return removals, nil
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"event":"user.deleted","user":{"id":"1dffa867-718b-4488-b07e-f838ef7b01e4"}}`)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := signWebhook("secret", timestamp, body)
+
+	assert.NoError(t, verifyWebhook("secret", timestamp, signature, body, now))
+	assert.Error(t, verifyWebhook("other", timestamp, signature, body, now))
+	assert.Error(t, verifyWebhook("secret", timestamp, signature, append(body, ' '), now))
+	assert.Error(t, verifyWebhook("secret", timestamp, signature, body, now.Add(10*time.Minute)))
+	assert.Error(t, verifyWebhook("secret", "yesterday", signature, body, now))
+}
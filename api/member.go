@@ -0,0 +1,281 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * Members of an Account
+ */
+
+// nullableTime tells an explicit `null` apart from a missing value
+type nullableTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON is only called if the value is present
+func (n *nullableTime) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Time = nil
+		return nil
+	}
+	t := time.Time{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	n.Time = &t
+	return nil
+}
+
+// MembersGet returns the active members of an account
+// [GET]/accounts/{id}/users
+func (a *API) MembersGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !(user.IsSuperAdmin || account.IsOwner(user.ID) || account.IsMember(user.ID)) {
+		return notFoundError("Account not found").WithErrorCode(errCodeAccountNotFound)
+	}
+
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError("Bad Pagination Parameters: %v", err).WithErrorCode(errCodeInvalidPage)
+	}
+
+	var members []*models.AccountUser
+	if pageParams.Keyset {
+		members, err = models.FindAccountUsersPage(a.db, account.ID, pageParams)
+	} else {
+		members, err = models.FindAccountUsers(a.db, account.ID)
+	}
+	if err != nil {
+		return internalServerError("Database error finding members").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	if pageParams.Keyset {
+		addPaginationHeaders(w, r, pageParams)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"users": members,
+	})
+}
+
+type memberUpdateParams struct {
+	RoleID    *uuid.UUID   `json:"role_id"`
+	ExpiresAt nullableTime `json:"expires_at"`
+}
+
+// MemberUpdate changes the role of a member or when the membership runs out,
+// `"expires_at": null` keeps the membership forever
+// Permission: account-users-invite, account-role-update to change the role
+// [PUT]/accounts/{id}/users/{userId}
+func (a *API) MemberUpdate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	memberID, err := uuid.FromString(chi.URLParam(r, "userId"))
+	if err != nil {
+		return badRequestError("Invalid User ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	params := &memberUpdateParams{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Member params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+
+	if !a.hasPermission(r, account, user, "account-users-invite") {
+		return unauthorizedError("You dont have `account-users-invite` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+	if params.RoleID != nil {
+		if !a.hasPermission(r, account, user, "account-role-update") {
+			return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+		}
+		if memberID == user.ID && !user.IsSuperAdmin {
+			return forbiddenError("You can not change your own role").WithErrorCode(errCodeOwnRole)
+		}
+	}
+
+	if params.ExpiresAt.Time != nil {
+		if account.IsOwner(memberID) {
+			return unprocessableEntityError("Memberships of owners can not expire").WithErrorCode(errCodeOwnerImmutable).WithField("expires_at")
+		}
+		if !params.ExpiresAt.Time.After(time.Now()) {
+			return unprocessableEntityError("expires_at must be in the future").WithErrorCode(errCodeInvalidExpiry).WithField("expires_at")
+		}
+	}
+
+	var member *models.AccountUser
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if member, terr = models.FindAccountUser(tx, account.ID, memberID); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(terr.Error()).WithErrorCode(errCodeMemberNotFound)
+			}
+			return internalServerError("Database error finding member").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		if params.RoleID != nil {
+			if _, terr = models.FindRoleByAccountAndID(tx, account.ID, *params.RoleID); terr != nil {
+				if models.IsNotFoundError(terr) {
+					return unprocessableEntityError("Role does not belong to this account").WithErrorCode(errCodeUnknownRole)
+				}
+				return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+			if terr = member.UpdateRole(tx, *params.RoleID); terr != nil {
+				return internalServerError("Error updating role of member").WithErrorCode(errCodeInternal).WithInternalError(terr)
+			}
+		}
+
+		if params.ExpiresAt.Set {
+			if terr = member.UpdateExpiresAt(tx, params.ExpiresAt.Time); terr != nil {
+				return internalServerError("Error updating expiry of member").WithErrorCode(errCodeInternal).WithInternalError(terr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + account.ID.String())
+
+	return sendJSON(w, http.StatusOK, member)
+}
+
+// AccountLeave removes the current user from an account,
+// the last owner has to transfer the ownership before leaving
+// [POST]/accounts/{id}/leave
+func (a *API) AccountLeave(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	accountID, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		return badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	var removal *models.AccountRemoval
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		// the cached account might miss owners or members added meanwhile,
+		// the lock keeps two owners from leaving at the same time
+		if terr := models.LockAccount(tx, accountID); terr != nil {
+			return internalServerError("Database error locking account").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		account, terr := models.FindAccountByID(tx, accountID)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(terr.Error()).WithErrorCode(errCodeAccountNotFound)
+			}
+			return internalServerError("Database error finding account").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		owner := account.IsOwner(user.ID)
+		if !owner && !account.IsMember(user.ID) {
+			return notFoundError("Account not found").WithErrorCode(errCodeAccountNotFound)
+		}
+		if account.IsLastOwner(user.ID) {
+			return conflictError("The last owner can not leave the account, transfer the ownership first").WithErrorCode(errCodeLastOwner)
+		}
+
+		if removal, terr = models.LeaveAccount(tx, account, user.ID); terr != nil {
+			return internalServerError("Database error leaving account").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		if terr = models.NewAuditLogEntry(tx, getInstanceID(ctx), account.ID, user.ID, user.ID, models.MemberLeftAction, map[string]interface{}{
+			"was_member": removal.WasMember,
+			"was_owner":  removal.WasOwner,
+		}); terr != nil {
+			return internalServerError("Database error saving audit log entry").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + accountID.String())
+
+	return sendJSON(w, http.StatusOK, removal)
+}
+
+// InvitationAccept confirms the pending invitation of the current user,
+// invited members have no permissions until they accept
+// [POST]/accounts/{id}/accept
+func (a *API) InvitationAccept(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	accountID, err := uuid.FromString(chi.URLParam(r, "id"))
+	if err != nil {
+		return badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	var member *models.AccountUser
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		member, terr = models.FindAccountUser(tx, accountID, user.ID)
+		if terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError("Invitation not found").WithErrorCode(errCodeInvitationNotFound)
+			}
+			return internalServerError("Database error finding account user").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if !member.IsPending() {
+			return notFoundError("Invitation not found").WithErrorCode(errCodeInvitationNotFound)
+		}
+
+		if terr = member.Confirm(tx); terr != nil {
+			return internalServerError("Database error accepting invitation").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		if terr = models.NewAuditLogEntry(tx, getInstanceID(ctx), accountID, user.ID, user.ID, models.InvitationAcceptedAction, map[string]interface{}{
+			"role_id":    member.RoleID,
+			"invited_by": member.InvitedBy,
+		}); terr != nil {
+			return internalServerError("Database error saving audit log entry").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + accountID.String())
+
+	return sendJSON(w, http.StatusOK, member)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	identity "github.com/delivc/identity/models"
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * Memberships of the current user
+ */
+
+// Status of a membership
+const (
+	membershipActive  = "active"
+	membershipInvited = "invited"
+)
+
+type membershipAccount struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Aud  string    `json:"aud"`
+}
+
+type membershipRole struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type membershipAssignment struct {
+	Role      membershipRole  `json:"role"`
+	Resource  models.Resource `json:"resource"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+}
+
+type membership struct {
+	Account     membershipAccount      `json:"account"`
+	Owner       bool                   `json:"owner"`
+	Status      string                 `json:"status"`
+	Role        *membershipRole        `json:"role"`
+	Assignments []membershipAssignment `json:"assignments"`
+	Permissions []string               `json:"permissions"`
+	InvitedAt   *time.Time             `json:"invited_at,omitempty"`
+	InvitedBy   *uuid.UUID             `json:"invited_by,omitempty"`
+	ConfirmedAt *time.Time             `json:"confirmed_at,omitempty"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+}
+
+// UserMembershipsGet returns every account of the current user with its role,
+// resource assignments, account wide permissions and invitation state
+// [GET]/user/memberships
+func (a *API) UserMembershipsGet(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	accounts, err := models.FindAccountsOfUser(a.db, user.ID)
+	if err != nil {
+		return internalServerError("Database error finding accounts").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	names, err := a.permissionNames()
+	if err != nil {
+		return err
+	}
+
+	memberships := []*membership{}
+	for _, account := range accounts {
+		m, err := a.membershipOf(r, account, user, names)
+		if err != nil {
+			return err
+		}
+		memberships = append(memberships, m)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"memberships": memberships,
+	})
+}
+
+// newMembership describes the ownership, role and invitation state of the user
+func newMembership(account *models.Account, userID uuid.UUID, rolesByID map[uuid.UUID]membershipRole) *membership {
+	m := &membership{
+		Account:     membershipAccount{ID: account.ID, Name: account.Name, Aud: account.Aud},
+		Owner:       account.IsOwner(userID),
+		Status:      membershipActive,
+		Assignments: []membershipAssignment{},
+		Permissions: []string{},
+	}
+
+	member := account.Membership(userID)
+	if member == nil {
+		return m
+	}
+	if role, ok := rolesByID[member.RoleID]; ok {
+		m.Role = &role
+	}
+	if member.IsPending() {
+		m.Status = membershipInvited
+	}
+	if member.InvitedBy != uuid.Nil {
+		invitedBy := member.InvitedBy
+		m.InvitedBy = &invitedBy
+	}
+	m.InvitedAt = member.InvitedAt
+	m.ConfirmedAt = member.ConfirmedAt
+	m.ExpiresAt = member.ExpiresAt
+	return m
+}
+
+func (a *API) membershipOf(r *http.Request, account *models.Account, user *identity.User, names []string) (*membership, error) {
+	userID := user.ID
+
+	roles, err := models.FindRolesByAccount(a.db, account.ID)
+	if err != nil {
+		return nil, internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	rolesByID := map[uuid.UUID]membershipRole{}
+	for _, role := range roles {
+		rolesByID[role.ID] = membershipRole{ID: role.ID, Name: role.Name}
+	}
+
+	m := newMembership(account, userID, rolesByID)
+	if account.Membership(userID) != nil {
+		assignments, err := models.FindRoleAssignmentsOfUser(a.db, account.ID, userID)
+		if err != nil {
+			return nil, internalServerError("Database error finding role assignments").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+		for _, assignment := range assignments {
+			m.Assignments = append(m.Assignments, membershipAssignment{
+				Role:      rolesByID[assignment.RoleID],
+				Resource:  assignment.Resource(),
+				ExpiresAt: assignment.ExpiresAt,
+			})
+		}
+	}
+
+	if user.IsSuperAdmin || m.Owner {
+		m.Permissions = names
+		return m, nil
+	}
+
+	decisions, err := account.EffectivePermissions(a.db, names, userID, nil, a.accessContext(r))
+	if err != nil {
+		return nil, internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	for _, decision := range decisions {
+		if decision.Allowed {
+			m.Permissions = append(m.Permissions, decision.Permission)
+		}
+	}
+	return m, nil
+}
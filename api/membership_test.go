@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserMembershipsGetRequiresUser(t *testing.T) {
+	err := (&API{}).UserMembershipsGet(httptest.NewRecorder(), httptest.NewRequest("GET", "/user/memberships", nil))
+	require.Error(t, err)
+	httpErr := err.(*HTTPError)
+	assert.Equal(t, 400, httpErr.Code)
+	assert.Equal(t, errCodeInvalidUser, httpErr.ErrorCode)
+}
+
+func TestNewMembership(t *testing.T) {
+	userID := uuid.Must(uuid.NewV4())
+	inviterID := uuid.Must(uuid.NewV4())
+	editors := membershipRole{ID: uuid.Must(uuid.NewV4()), Name: "Editors"}
+	rolesByID := map[uuid.UUID]membershipRole{editors.ID: editors}
+	now := time.Now()
+
+	// owners without a membership have no role
+	account := &models.Account{ID: uuid.Must(uuid.NewV4()), Name: "Acme"}
+	account.SetOwners([]uuid.UUID{userID})
+	m := newMembership(account, userID, rolesByID)
+	assert.True(t, m.Owner)
+	assert.Equal(t, membershipActive, m.Status)
+	assert.Nil(t, m.Role)
+
+	account = &models.Account{ID: uuid.Must(uuid.NewV4()), AccountUser: []models.AccountUser{
+		{UserID: userID, RoleID: editors.ID, InvitedAt: &now, InvitedBy: inviterID},
+	}}
+	m = newMembership(account, userID, rolesByID)
+	assert.False(t, m.Owner)
+	assert.Equal(t, membershipInvited, m.Status)
+	assert.Equal(t, &editors, m.Role)
+	assert.Equal(t, &inviterID, m.InvitedBy)
+
+	account.AccountUser[0].ConfirmedAt = &now
+	m = newMembership(account, userID, rolesByID)
+	assert.Equal(t, membershipActive, m.Status)
+	assert.Equal(t, &now, m.ConfirmedAt)
+
+	// members invited by the system have no inviter
+	account.AccountUser[0].InvitedBy = uuid.Nil
+	assert.Nil(t, newMembership(account, userID, rolesByID).InvitedBy)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+)
+
+/**
+ * JSON Merge Patch, RFC 7396
+ */
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// mergePatch applies a merge patch to a document,
+// `null` removes a member and objects are merged recursively
+func mergePatch(target map[string]interface{}, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = map[string]interface{}{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchObject, ok := value.(map[string]interface{}); ok {
+			targetObject, _ := target[key].(map[string]interface{})
+			target[key] = mergePatch(targetObject, patchObject)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
+
+// readMergePatch reads the merge patch of a request,
+// a patch which is no object would replace the whole resource and is rejected
+func readMergePatch(r *http.Request) (map[string]interface{}, error) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			return nil, httpError(http.StatusUnsupportedMediaType, "Content-Type must be %v", mergePatchContentType).WithErrorCode(errCodeUnsupportedMedia)
+		}
+	}
+
+	var patch interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return nil, badRequestError("Could not read merge patch: %v", err).WithErrorCode(errCodeInvalidPatch)
+	}
+	object, ok := patch.(map[string]interface{})
+	if !ok {
+		return nil, unprocessableEntityError("Merge patch must be a JSON object").WithErrorCode(errCodeInvalidPatch)
+	}
+	return object, nil
+}
+
+// toDocument turns a value into the generic document a merge patch applies to
+func toDocument(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	document := map[string]interface{}{}
+	return document, json.Unmarshal(data, &document)
+}
+
+// fromDocument reads a patched document back into a typed value
+func fromDocument(document map[string]interface{}, value interface{}) error {
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// checkPatchable rejects patches of attributes which are not part of the document
+func checkPatchable(patch map[string]interface{}, attributes ...string) error {
+	allowed := map[string]bool{}
+	for _, attribute := range attributes {
+		allowed[attribute] = true
+	}
+	for key := range patch {
+		if !allowed[key] {
+			return unprocessableEntityError("Attribute '%v' can not be changed", key).WithErrorCode(errCodeReadOnly).WithField(key)
+		}
+	}
+	return nil
+}
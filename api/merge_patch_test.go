@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	// examples of RFC 7396, Appendix A
+	cases := []struct{ target, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		target := map[string]interface{}{}
+		patch := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(c.target), &target))
+		require.NoError(t, json.Unmarshal([]byte(c.patch), &patch))
+
+		result, err := json.Marshal(mergePatch(target, patch))
+		require.NoError(t, err)
+		assert.JSONEq(t, c.result, string(result), c.patch)
+	}
+}
+
+func TestReadMergePatch(t *testing.T) {
+	r := httptest.NewRequest("PATCH", "/accounts/1", strings.NewReader(`{"billing_details":null}`))
+	r.Header.Set("Content-Type", mergePatchContentType)
+	patch, err := readMergePatch(r)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"billing_details": nil}, patch)
+
+	r = httptest.NewRequest("PATCH", "/accounts/1", strings.NewReader(`["name"]`))
+	_, err = readMergePatch(r)
+	assert.Error(t, err)
+
+	r = httptest.NewRequest("PATCH", "/accounts/1", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", "text/plain")
+	_, err = readMergePatch(r)
+	require.Error(t, err)
+	assert.Equal(t, 415, err.(*HTTPError).Code)
+
+	assert.Error(t, checkPatchable(map[string]interface{}{"id": "x"}, "name"))
+	assert.NoError(t, checkPatchable(map[string]interface{}{"name": "x"}, "name"))
+}
@@ -0,0 +1,505 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * OpenAPI 3 description of the API
+ */
+
+const openAPIVersion = "3.0.3"
+
+// Security schemes of the operations
+const (
+	securityToken    = "token"
+	securityOperator = "operatorToken"
+	securitySCIM     = "scimToken"
+	securityWebhook  = "webhookSignature"
+)
+
+// properties documents a JSON object by example values of its members,
+// used for the envelopes the handlers build with maps
+type properties map[string]interface{}
+
+// scimUserList and scimGroupList document the resources of a scimListResponse
+type scimUserList struct {
+	scimListResponse
+	Resources []scimUser `json:"Resources"`
+}
+
+type scimGroupList struct {
+	scimListResponse
+	Resources []scimGroup `json:"Resources"`
+}
+
+// openAPIOperation documents a route, the schemas of the request
+// and response are derived from the example values Params and Response.
+// Paths are documented below every version unless Unversioned is set
+type openAPIOperation struct {
+	Method      string
+	Path        string
+	Unversioned bool
+	ID          string
+	Summary     string
+	Tag         string
+	Security    string
+	Query       []string
+	ContentType string
+	Params      interface{}
+	Status      int
+	Response    interface{}
+}
+
+// openAPIQuery are the query parameters an operation can refer to
+var openAPIQuery = map[string]struct {
+	Type        string
+	Description string
+}{
+	"page":        {"integer", "Page of an offset paginated list"},
+	"per_page":    {"integer", "Items per page of an offset paginated list"},
+	"cursor":      {"string", "Opaque cursor of the next page, taken from the `Link` header"},
+	"limit":       {"integer", "Items per page of a cursor paginated list"},
+	"sort":        {"string", "Sort fields, eg. `created_at desc,name asc`"},
+	"filter":      {"string", "Filter in the form `field:operator:value`, can be repeated"},
+	"fields":      {"string", "Comma separated attributes to return"},
+	"include":     {"string", "Comma separated associations to embed"},
+	"namespace":   {"string", "Namespace of the permissions"},
+	"permission":  {"string", "Name of the permission to decide"},
+	"resource":    {"string", "Resource in the `type:id` notation"},
+	"on_conflict": {"string", "`fail`, `replace` or `copy` if the account exists already"},
+	"reassign_to": {"string", "Role the members of the deleted role move to"},
+	"status":      {"string", "`pending`, `approved` or `denied`"},
+	"scimFilter":  {"string", "SCIM filter, only `eq` is supported"},
+	"startIndex":  {"integer", "1-based index of the first result"},
+	"count":       {"integer", "Maximum number of results"},
+}
+
+var paginationQuery = []string{"page", "per_page", "cursor", "limit"}
+
+var openAPIOperations = []openAPIOperation{
+	{Method: http.MethodGet, Path: "/health", Unversioned: true, ID: "HealthCheck", Summary: "Version and memory usage of the service", Tag: "Service",
+		Response: map[string]string{}},
+	{Method: http.MethodGet, Path: "/openapi.json", ID: "OpenAPIGet", Summary: "This document", Tag: "Service",
+		Response: map[string]interface{}{}},
+
+	{Method: http.MethodPost, Path: "/hooks/identity", ID: "IdentityHook", Summary: "Removes deleted and disabled users of Identity", Tag: "Hooks", Security: securityWebhook,
+		Params: identityEvent{}, Response: properties{"accounts": []*models.AccountRemoval{}}},
+
+	{Method: http.MethodPost, Path: "/operator/permissions", ID: "PermissionsRegister", Summary: "Registers the permissions of a service", Tag: "Operator", Security: securityOperator,
+		Params: registerPermissionsParams{}, Response: properties{"permissions": []models.Permission{}}},
+	{Method: http.MethodPost, Path: "/operator/permissions/{name}/deprecate", ID: "PermissionDeprecate", Summary: "Deprecates a permission", Tag: "Operator", Security: securityOperator,
+		Response: models.Permission{}},
+	{Method: http.MethodDelete, Path: "/operator/permissions/{name}", ID: "PermissionRetire", Summary: "Retires a deprecated permission", Tag: "Operator", Security: securityOperator,
+		Response: properties{}},
+	{Method: http.MethodGet, Path: "/operator/users/{userId}", ID: "UserDataExport", Summary: "Exports everything stored about a user", Tag: "Operator", Security: securityOperator,
+		Response: models.UserData{}},
+	{Method: http.MethodDelete, Path: "/operator/users/{userId}", ID: "UserDataErase", Summary: "Removes and anonymizes a user", Tag: "Operator", Security: securityOperator,
+		Response: properties{"user_id": uuid.UUID{}, "accounts": []*models.AccountRemoval{}}},
+
+	{Method: http.MethodGet, Path: "/scim/v2/Users", ID: "SCIMUsersGet", Summary: "Lists the provisioned users", Tag: "SCIM", Security: securitySCIM,
+		Query: []string{"scimFilter", "startIndex", "count"}, Response: scimUserList{}},
+	{Method: http.MethodPost, Path: "/scim/v2/Users", ID: "SCIMUserCreate", Summary: "Provisions a user", Tag: "SCIM", Security: securitySCIM,
+		Params: scimUserParams{}, Status: http.StatusCreated, Response: scimUser{}},
+	{Method: http.MethodGet, Path: "/scim/v2/Users/{userId}", ID: "SCIMUserGet", Summary: "Returns a provisioned user", Tag: "SCIM", Security: securitySCIM,
+		Response: scimUser{}},
+	{Method: http.MethodPut, Path: "/scim/v2/Users/{userId}", ID: "SCIMUserReplace", Summary: "Replaces a provisioned user", Tag: "SCIM", Security: securitySCIM,
+		Params: scimUserParams{}, Response: scimUser{}},
+	{Method: http.MethodPatch, Path: "/scim/v2/Users/{userId}", ID: "SCIMUserPatch", Summary: "Patches a provisioned user", Tag: "SCIM", Security: securitySCIM,
+		Params: scimPatchRequest{}, Response: scimUser{}},
+	{Method: http.MethodDelete, Path: "/scim/v2/Users/{userId}", ID: "SCIMUserDestroy", Summary: "Deprovisions a user", Tag: "SCIM", Security: securitySCIM,
+		Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/scim/v2/Groups", ID: "SCIMGroupsGet", Summary: "Lists the roles of the account", Tag: "SCIM", Security: securitySCIM,
+		Query: []string{"scimFilter", "startIndex", "count"}, Response: scimGroupList{}},
+	{Method: http.MethodPost, Path: "/scim/v2/Groups", ID: "SCIMGroupCreate", Summary: "Creates a role", Tag: "SCIM", Security: securitySCIM,
+		Params: scimGroupParams{}, Status: http.StatusCreated, Response: scimGroup{}},
+	{Method: http.MethodGet, Path: "/scim/v2/Groups/{groupId}", ID: "SCIMGroupGet", Summary: "Returns a role with its provisioned members", Tag: "SCIM", Security: securitySCIM,
+		Response: scimGroup{}},
+	{Method: http.MethodPut, Path: "/scim/v2/Groups/{groupId}", ID: "SCIMGroupReplace", Summary: "Replaces the name and members of a role", Tag: "SCIM", Security: securitySCIM,
+		Params: scimGroupParams{}, Response: scimGroup{}},
+	{Method: http.MethodPatch, Path: "/scim/v2/Groups/{groupId}", ID: "SCIMGroupPatch", Summary: "Patches the name and members of a role", Tag: "SCIM", Security: securitySCIM,
+		Params: scimPatchRequest{}, Response: scimGroup{}},
+	{Method: http.MethodDelete, Path: "/scim/v2/Groups/{groupId}", ID: "SCIMGroupDestroy", Summary: "Deletes a role", Tag: "SCIM", Security: securitySCIM,
+		Status: http.StatusNoContent},
+
+	{Method: http.MethodGet, Path: "/accounts", ID: "AccountsGet", Summary: "Lists the accounts of the user", Tag: "Accounts", Security: securityToken,
+		Query: append([]string{"sort", "filter", "fields", "include"}, paginationQuery...), Response: properties{"accounts": []*models.Account{}, "aud": ""}},
+	{Method: http.MethodPost, Path: "/accounts", ID: "AccountCreate", Summary: "Creates an account owned by the user", Tag: "Accounts", Security: securityToken,
+		Params: accountCreateParams{}, Response: models.Account{}},
+	{Method: http.MethodPost, Path: "/accounts/import", ID: "AccountImport", Summary: "Imports an exported account", Tag: "Accounts", Security: securityToken,
+		Query: []string{"on_conflict"}, Params: models.AccountExport{}, Status: http.StatusCreated, Response: models.ImportResult{}},
+	{Method: http.MethodGet, Path: "/accounts/{id}", ID: "AccountGet", Summary: "Returns an account", Tag: "Accounts", Security: securityToken,
+		Query: []string{"fields", "include"}, Response: models.Account{}},
+	{Method: http.MethodPut, Path: "/accounts/{id}", ID: "AccountsUpdate", Summary: "Updates an account", Tag: "Accounts", Security: securityToken,
+		Params: accountUpdateParams{}, Response: models.Account{}},
+	{Method: http.MethodPatch, Path: "/accounts/{id}", ID: "AccountPatch", Summary: "Applies a JSON merge patch to an account", Tag: "Accounts", Security: securityToken,
+		ContentType: mergePatchContentType, Params: accountDocument{}, Response: models.Account{}},
+	{Method: http.MethodDelete, Path: "/accounts/{id}", ID: "AccountDelete", Summary: "Deletes an account", Tag: "Accounts", Security: securityToken,
+		Response: properties{}},
+	{Method: http.MethodGet, Path: "/accounts/{id}/authorize", ID: "AccountAuthorize", Summary: "Decides a permission for the user", Tag: "Authorization", Security: securityToken,
+		Query: []string{"permission", "resource"}, Response: properties{"permission": "", "resource": &models.Resource{}, "allowed": false, "decision": models.Decision{}}},
+	{Method: http.MethodGet, Path: "/accounts/{id}/effective-permissions", ID: "EffectivePermissionsGet", Summary: "Decides every permission for the user", Tag: "Authorization", Security: securityToken,
+		Query: []string{"resource"}, Response: properties{"resource": &models.Resource{}, "permissions": []models.Decision{}}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/leave", ID: "AccountLeave", Summary: "Removes the user from an account", Tag: "Members", Security: securityToken,
+		Response: models.AccountRemoval{}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/accept", ID: "InvitationAccept", Summary: "Accepts the invitation to an account", Tag: "Members", Security: securityToken,
+		Response: models.AccountUser{}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/batch", ID: "AccountBatch", Summary: "Runs role and member operations in one transaction", Tag: "Accounts", Security: securityToken,
+		Params: batchParams{}, Response: properties{"results": []*batchResult{}}},
+	{Method: http.MethodGet, Path: "/accounts/{id}/export", ID: "AccountExport", Summary: "Exports an account", Tag: "Accounts", Security: securityToken,
+		Response: models.AccountExport{}},
+
+	{Method: http.MethodGet, Path: "/permissions", ID: "PermissionsGet", Summary: "Lists the registered permissions", Tag: "Permissions", Security: securityToken,
+		Query: append([]string{"namespace", "sort"}, paginationQuery...), Response: properties{"permissions": []*models.Permission{}}},
+
+	{Method: http.MethodGet, Path: "/user/memberships", ID: "UserMembershipsGet", Summary: "Lists the memberships of the user", Tag: "Members", Security: securityToken,
+		Response: properties{"memberships": []membership{}}},
+	{Method: http.MethodPost, Path: "/user/domain-join", ID: "UserDomainJoin", Summary: "Joins the accounts of the email domain of the user", Tag: "Domains", Security: securityToken,
+		Response: properties{"joined": []*models.AccountUser{}}},
+
+	{Method: http.MethodGet, Path: "/accounts/{id}/role", ID: "RolesGet", Summary: "Lists the roles of an account", Tag: "Roles", Security: securityToken,
+		Query: paginationQuery, Response: properties{"roles": []*models.Role{}}},
+	{Method: http.MethodGet, Path: "/accounts/{id}/role/{roleId}", ID: "RoleGet", Summary: "Returns a role", Tag: "Roles", Security: securityToken,
+		Response: models.Role{}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/role", ID: "RoleCreate", Summary: "Creates a role", Tag: "Roles", Security: securityToken,
+		Params: createRoleRequest{}, Response: models.Role{}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/role/sync", ID: "AccountRolesSync", Summary: "Updates the roles created from templates", Tag: "Roles", Security: securityToken,
+		Response: properties{"roles": []*models.Role{}}},
+	{Method: http.MethodDelete, Path: "/accounts/{id}/role/{roleId}", ID: "RoleDestroy", Summary: "Deletes a role", Tag: "Roles", Security: securityToken,
+		Query: []string{"reassign_to"}, Response: properties{}},
+	{Method: http.MethodPut, Path: "/accounts/{id}/role/{roleId}", ID: "RoleUpdate", Summary: "Updates a role", Tag: "Roles", Security: securityToken,
+		Params: updateRoleRequest{}, Response: models.Role{}},
+	{Method: http.MethodPatch, Path: "/accounts/{id}/role/{roleId}", ID: "RolePatch", Summary: "Applies a JSON merge patch to a role", Tag: "Roles", Security: securityToken,
+		ContentType: mergePatchContentType, Params: roleDocument{}, Response: models.Role{}},
+
+	{Method: http.MethodGet, Path: "/accounts/{id}/users", ID: "MembersGet", Summary: "Lists the members of an account", Tag: "Members", Security: securityToken,
+		Query: paginationQuery, Response: properties{"users": []*models.AccountUser{}}},
+	{Method: http.MethodPut, Path: "/accounts/{id}/users/{userId}", ID: "MemberUpdate", Summary: "Changes the role or expiry of a member", Tag: "Members", Security: securityToken,
+		Params: memberUpdateParams{}, Response: models.AccountUser{}},
+
+	{Method: http.MethodGet, Path: "/accounts/{id}/assignments", ID: "RoleAssignmentsGet", Summary: "Lists the resource scoped role assignments", Tag: "Roles", Security: securityToken,
+		Response: properties{"assignments": []*models.RoleAssignment{}}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/assignments", ID: "RoleAssignmentCreate", Summary: "Assigns a role for a single resource", Tag: "Roles", Security: securityToken,
+		Params: createRoleAssignmentRequest{}, Response: models.RoleAssignment{}},
+	{Method: http.MethodDelete, Path: "/accounts/{id}/assignments/{assignmentId}", ID: "RoleAssignmentDestroy", Summary: "Removes a role assignment", Tag: "Roles", Security: securityToken,
+		Response: properties{}},
+
+	{Method: http.MethodPost, Path: "/accounts/{id}/scim-token", ID: "SCIMTokenCreate", Summary: "Creates the SCIM token of an account", Tag: "SCIM", Security: securityToken,
+		Params: createSCIMTokenParams{}, Status: http.StatusCreated, Response: properties{"token": "", "scim_token": models.SCIMToken{}}},
+	{Method: http.MethodDelete, Path: "/accounts/{id}/scim-token", ID: "SCIMTokenDestroy", Summary: "Revokes the SCIM token of an account", Tag: "SCIM", Security: securityToken,
+		Response: properties{}},
+
+	{Method: http.MethodGet, Path: "/accounts/{id}/domains", ID: "DomainsGet", Summary: "Lists the claimed email domains", Tag: "Domains", Security: securityToken,
+		Response: properties{"domains": []*models.AccountDomain{}}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/domains", ID: "DomainCreate", Summary: "Claims an email domain", Tag: "Domains", Security: securityToken,
+		Params: createDomainParams{}, Status: http.StatusCreated, Response: properties{"domain": models.AccountDomain{}, "txt_record": ""}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/domains/{domainId}/verify", ID: "DomainVerify", Summary: "Verifies the TXT record of a domain", Tag: "Domains", Security: securityToken,
+		Response: models.AccountDomain{}},
+	{Method: http.MethodDelete, Path: "/accounts/{id}/domains/{domainId}", ID: "DomainDestroy", Summary: "Releases a domain", Tag: "Domains", Security: securityToken,
+		Response: properties{}},
+
+	{Method: http.MethodGet, Path: "/accounts/{id}/access-requests", ID: "AccessRequestsGet", Summary: "Lists the requests to join an account", Tag: "Access Requests", Security: securityToken,
+		Query: []string{"status"}, Response: properties{"access_requests": []*models.AccessRequest{}}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/access-requests", ID: "AccessRequestCreate", Summary: "Asks to join an account", Tag: "Access Requests", Security: securityToken,
+		Params: createAccessRequestParams{}, Status: http.StatusCreated, Response: models.AccessRequest{}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/access-requests/{requestId}/approve", ID: "AccessRequestApprove", Summary: "Adds the requester as member", Tag: "Access Requests", Security: securityToken,
+		Params: approveAccessRequestParams{}, Response: models.AccessRequest{}},
+	{Method: http.MethodPost, Path: "/accounts/{id}/access-requests/{requestId}/deny", ID: "AccessRequestDeny", Summary: "Denies a request", Tag: "Access Requests", Security: securityToken,
+		Response: models.AccessRequest{}},
+}
+
+var (
+	openAPIPathParamRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+	uuidType         = reflect.TypeOf(uuid.UUID{})
+	timeType         = reflect.TypeOf(time.Time{})
+	nullableTimeType = reflect.TypeOf(nullableTime{})
+	rawMessageType   = reflect.TypeOf(json.RawMessage{})
+)
+
+// OpenAPIGet returns the OpenAPI 3 document of the API
+// [GET]/openapi.json
+func (a *API) OpenAPIGet(w http.ResponseWriter, r *http.Request) error {
+	return sendJSON(w, http.StatusOK, a.openAPIDocument())
+}
+
+func (a *API) openAPIDocument() map[string]interface{} {
+	g := &openAPIGenerator{schemas: map[string]interface{}{}}
+	errorSchema := g.schemaOf(reflect.TypeOf(HTTPError{}))
+	problemSchema := g.schemaOf(reflect.TypeOf(Problem{}))
+	scimErrorSchema := g.schemaOf(reflect.TypeOf(SCIMError{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range openAPIOperations {
+		contentType, errSchema := "application/json", errorSchema
+		if op.Security == securitySCIM {
+			contentType, errSchema = scimContentType, scimErrorSchema
+		}
+
+		operation := map[string]interface{}{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"parameters":  openAPIParameters(op),
+		}
+		if op.Security != "" {
+			operation["security"] = []map[string][]string{{op.Security: {}}}
+		}
+		if op.Params != nil {
+			requestType := contentType
+			if op.ContentType != "" {
+				requestType = op.ContentType
+			}
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{requestType: map[string]interface{}{"schema": g.schemaFor(op.Params)}},
+			}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := map[string]interface{}{"description": http.StatusText(status)}
+		if op.Response != nil {
+			response["content"] = map[string]interface{}{contentType: map[string]interface{}{"schema": g.schemaFor(op.Response)}}
+		}
+		errorContent := map[string]interface{}{contentType: map[string]interface{}{"schema": errSchema}}
+		if op.Security != securitySCIM {
+			errorContent[problemContentType] = map[string]interface{}{"schema": problemSchema}
+		}
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(status): response,
+			"default": map[string]interface{}{
+				"description": "Error, as problem details if the request accepts " + problemContentType,
+				"content":     errorContent,
+			},
+		}
+
+		for _, path := range op.paths() {
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+			paths[path][strings.ToLower(op.Method)] = operation
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "Team",
+			"description": "Team is a management Service for Delivc Teams",
+			"version":     a.version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				securityToken:    map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT", "description": "Token of an Identity user"},
+				securityOperator: map[string]interface{}{"type": "http", "scheme": "bearer", "description": "Operator token of the instance"},
+				securitySCIM:     map[string]interface{}{"type": "http", "scheme": "bearer", "description": "SCIM token of an account"},
+				securityWebhook:  map[string]interface{}{"type": "apiKey", "in": "header", "name": webhookSignatureHeader, "description": "HMAC-SHA256 of the body, see " + webhookTimestampHeader},
+			},
+		},
+	}
+}
+
+// paths returns the path of the operation in every version of the API
+func (op openAPIOperation) paths() []string {
+	if op.Unversioned {
+		return []string{op.Path}
+	}
+	paths := []string{}
+	for _, version := range apiVersions {
+		paths = append(paths, "/"+version+op.Path)
+	}
+	return paths
+}
+
+// openAPIParameters documents the path parameters of the route and its query parameters
+func openAPIParameters(op openAPIOperation) []map[string]interface{} {
+	parameters := []map[string]interface{}{}
+	for _, match := range openAPIPathParamRegexp.FindAllStringSubmatch(op.Path, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		if match[1] != "name" {
+			schema["format"] = "uuid"
+		}
+		parameters = append(parameters, map[string]interface{}{"name": match[1], "in": "path", "required": true, "schema": schema})
+	}
+	for _, name := range op.Query {
+		query := openAPIQuery[name]
+		if name == "scimFilter" {
+			name = "filter"
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": query.Description,
+			"schema":      map[string]interface{}{"type": query.Type},
+		})
+	}
+	return parameters
+}
+
+// openAPIGenerator derives schemas from Go types the way encoding/json
+// serializes them, named structs become components
+type openAPIGenerator struct {
+	schemas map[string]interface{}
+}
+
+// schemaFor returns the schema of an example value
+func (g *openAPIGenerator) schemaFor(value interface{}) map[string]interface{} {
+	if props, ok := value.(properties); ok {
+		object := map[string]interface{}{}
+		for name, property := range props {
+			object[name] = g.schemaFor(property)
+		}
+		return map[string]interface{}{"type": "object", "properties": object}
+	}
+	return g.schemaOf(reflect.TypeOf(value))
+}
+
+func (g *openAPIGenerator) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case uuidType:
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case nullableTimeType:
+		return map[string]interface{}{"type": "string", "format": "date-time", "nullable": true}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.objectOf(t)
+		}
+		if _, exists := g.schemas[t.Name()]; !exists {
+			// registered before its fields, so recursive types terminate
+			g.schemas[t.Name()] = map[string]interface{}{}
+			g.schemas[t.Name()] = g.objectOf(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+func (g *openAPIGenerator) objectOf(t reflect.Type) map[string]interface{} {
+	object := map[string]interface{}{}
+	g.fields(t, object)
+	return map[string]interface{}{"type": "object", "properties": object}
+}
+
+// fields collects the JSON members of a struct, members of embedded structs
+// are promoted unless the struct declares a member of the same name
+func (g *openAPIGenerator) fields(t reflect.Type, object map[string]interface{}) {
+	embedded := []reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma:]
+		}
+
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := g.schemaOf(fieldType)
+		constrain(schema, field.Tag.Get("validate"))
+		if fieldType.Kind() == reflect.Ptr && !strings.Contains(options, "omitempty") {
+			schema = nullable(schema)
+		}
+		object[name] = schema
+	}
+
+	for _, embeddedType := range embedded {
+		promoted := map[string]interface{}{}
+		g.fields(embeddedType, promoted)
+		for name, schema := range promoted {
+			if _, exists := object[name]; !exists {
+				object[name] = schema
+			}
+		}
+	}
+}
+
+// constrain documents the `validate` rules of a field,
+// `required` depends on the operation and is left out
+func constrain(schema map[string]interface{}, rules string) {
+	if rules == "" {
+		return
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		rule, arg := splitRule(rule)
+		switch {
+		case rule == "max" && schema["type"] == "string":
+			schema["maxLength"] = arg
+		case rule == "min" && schema["type"] == "string":
+			schema["minLength"] = arg
+		case rule == "max" && schema["type"] == "array":
+			schema["maxItems"] = arg
+		case rule == "min" && schema["type"] == "array":
+			schema["minItems"] = arg
+		case rule == "unique":
+			schema["uniqueItems"] = true
+		case rule == "email":
+			schema["format"] = "email"
+		}
+	}
+}
+
+// nullable marks a schema as nullable, references can not have siblings
+func nullable(schema map[string]interface{}) map[string]interface{} {
+	if _, ok := schema["$ref"]; ok {
+		return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+	}
+	schema["nullable"] = true
+	return schema
+}
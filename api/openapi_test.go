@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/delivc/team/conf"
+	"github.com/go-chi/chi/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	api := New(context.Background(), &conf.GlobalConfiguration{}, nil, "test")
+	paths := api.openAPIDocument()["paths"].(map[string]map[string]interface{})
+
+	routes := map[string]bool{}
+	err := chi.Walk(api.routes.chi, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		// patterns of mounted routers end with `/*`, the index of a
+		// nested router is served with and without trailing slash
+		for strings.Contains(route, "/*/") {
+			route = strings.Replace(route, "/*/", "/", -1)
+		}
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		// unversioned paths are aliases of the legacy version
+		if _, documented := paths[route]; !documented && !strings.HasPrefix(route, "/"+legacyVersion+"/") {
+			route = "/" + legacyVersion + route
+		}
+		routes[method+" "+route] = true
+		assert.Contains(t, paths[route], strings.ToLower(method), "%v %v is not documented", method, route)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, routes)
+
+	for path, operations := range paths {
+		for method := range operations {
+			assert.True(t, routes[strings.ToUpper(method)+" "+path], "%v %v is documented but not routed", method, path)
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	api := New(context.Background(), &conf.GlobalConfiguration{}, nil, "test")
+	document := api.openAPIDocument()
+	_, err := json.Marshal(document)
+	require.NoError(t, err)
+
+	schemas := document["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	httpError := schemas["HTTPError"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Len(t, httpError, 5)
+	assert.Contains(t, httpError, "msg")
+	assert.Contains(t, schemas, "Problem")
+
+	// hidden members are left out, embedded structs are flattened
+	role := schemas["Role"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.NotContains(t, role, "AccountID")
+	assert.Contains(t, role, "permissions")
+	operation := schemas["batchOperation"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Contains(t, operation, "permissions")
+	assert.Equal(t, true, operation["role_id"].(map[string]interface{})["nullable"])
+
+	list := schemas["scimUserList"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, "array", list["Resources"].(map[string]interface{})["type"])
+}
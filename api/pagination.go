@@ -1,16 +1,23 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 
 	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
 )
 
 const defaultPerPage = 50
 
+// maxPerPage caps the limit of a cursor page
+const maxPerPage = 1000
+
 func calculateTotalPages(perPage, total uint64) uint64 {
 	pages := total / perPage
 	if total%perPage > 0 {
@@ -19,7 +26,29 @@ func calculateTotalPages(perPage, total uint64) uint64 {
 	return pages
 }
 
+// encodeCursor turns a cursor into an opaque token for `?cursor=`
+func encodeCursor(c *models.Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*models.Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("bad cursor")
+	}
+	c := &models.Cursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.ID == uuid.Nil {
+		return nil, errors.New("bad cursor")
+	}
+	return c, nil
+}
+
 func addPaginationHeaders(w http.ResponseWriter, r *http.Request, p *models.Pagination) {
+	if p.Keyset {
+		addCursorHeaders(w, r, p)
+		return
+	}
 	totalPages := calculateTotalPages(p.PerPage, p.Count)
 	url, _ := url.ParseRequestURI(r.URL.String())
 	query := url.Query()
@@ -37,8 +66,29 @@ func addPaginationHeaders(w http.ResponseWriter, r *http.Request, p *models.Pagi
 	w.Header().Add("X-Total-Count", fmt.Sprintf("%v", p.Count))
 }
 
+// addCursorHeaders links the next page, there is no total in cursor mode
+func addCursorHeaders(w http.ResponseWriter, r *http.Request, p *models.Pagination) {
+	if p.Next == nil {
+		return
+	}
+	url, _ := url.ParseRequestURI(r.URL.String())
+	query := url.Query()
+	query.Set("cursor", encodeCursor(p.Next))
+	query.Set("limit", fmt.Sprintf("%v", p.PerPage))
+	url.RawQuery = query.Encode()
+	w.Header().Add("Link", "<"+url.String()+">; rel=\"next\"")
+}
+
+// paginate reads `?page=` and `?per_page=`, or switches to cursor mode
+// if `?cursor=` or `?limit=` is given
 func paginate(r *http.Request) (*models.Pagination, error) {
 	params := r.URL.Query()
+	if _, ok := params["cursor"]; ok {
+		return paginateCursor(params)
+	}
+	if _, ok := params["limit"]; ok {
+		return paginateCursor(params)
+	}
 	queryPage := params.Get("page")
 	queryPerPage := params.Get("per_page")
 	var page uint64 = 1
@@ -62,3 +112,31 @@ func paginate(r *http.Request) (*models.Pagination, error) {
 		PerPage: perPage,
 	}, nil
 }
+
+func paginateCursor(params url.Values) (*models.Pagination, error) {
+	if _, ok := params["sort"]; ok {
+		return nil, errors.New("sort is not supported with a cursor, pages are ordered from new to old")
+	}
+	p := &models.Pagination{
+		Keyset:  true,
+		PerPage: defaultPerPage,
+	}
+	if queryLimit := params.Get("limit"); queryLimit != "" {
+		limit, err := strconv.ParseUint(queryLimit, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if limit == 0 || limit > maxPerPage {
+			return nil, fmt.Errorf("limit must be between 1 and %v", maxPerPage)
+		}
+		p.PerPage = limit
+	}
+	if token := params.Get("cursor"); token != "" {
+		cursor, err := decodeCursor(token)
+		if err != nil {
+			return nil, err
+		}
+		p.Cursor = cursor
+	}
+	return p, nil
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginateCursor(t *testing.T) {
+	cursor := &models.Cursor{CreatedAt: time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC), ID: uuid.Must(uuid.NewV4())}
+
+	p, err := paginate(httptest.NewRequest("GET", "/accounts?limit=10&cursor="+encodeCursor(cursor), nil))
+	require.NoError(t, err)
+	assert.True(t, p.Keyset)
+	assert.Equal(t, uint64(10), p.PerPage)
+	assert.Equal(t, cursor, p.Cursor)
+
+	p, err = paginate(httptest.NewRequest("GET", "/accounts?limit=10", nil))
+	require.NoError(t, err)
+	assert.True(t, p.Keyset)
+	assert.Nil(t, p.Cursor)
+
+	p, err = paginate(httptest.NewRequest("GET", "/accounts?page=2", nil))
+	require.NoError(t, err)
+	assert.False(t, p.Keyset)
+	assert.Equal(t, uint64(2), p.Page)
+
+	for _, bad := range []string{"cursor=nope", "limit=0", "limit=5000", "limit=10&sort=name"} {
+		_, err = paginate(httptest.NewRequest("GET", "/accounts?"+bad, nil))
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestCursorHeaders(t *testing.T) {
+	r := httptest.NewRequest("GET", "/accounts?limit=2", nil)
+	w := httptest.NewRecorder()
+	addPaginationHeaders(w, r, &models.Pagination{Keyset: true, PerPage: 2})
+	assert.Empty(t, w.Header().Get("Link"))
+
+	next := &models.Cursor{CreatedAt: time.Now().UTC().Truncate(time.Second), ID: uuid.Must(uuid.NewV4())}
+	w = httptest.NewRecorder()
+	addPaginationHeaders(w, r, &models.Pagination{Keyset: true, PerPage: 2, Next: next})
+	assert.Equal(t, `</accounts?cursor=`+encodeCursor(next)+`&limit=2>; rel="next"`, w.Header().Get("Link"))
+}
@@ -1,32 +1,126 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
 )
 
 // PermissionsGet returns a list of all Permissions
+// [GET]/permissions?namespace=spaces
 func (a *API) PermissionsGet(w http.ResponseWriter, r *http.Request) error {
 	// this is available for all who are signed in
 	// no security checks
 
 	pageParams, err := paginate(r)
 	if err != nil {
-		return badRequestError("Bad Pagination Parameters: %v", err)
+		return badRequestError("Bad Pagination Parameters: %v", err).WithErrorCode(errCodeInvalidPage)
 	}
 
 	sortParams, err := sort(r, map[string]bool{models.CreatedAt: true}, []models.SortField{models.SortField{Name: models.CreatedAt, Dir: models.Descending}})
 	if err != nil {
-		return badRequestError("Bad Sort Parameters: %v", err)
+		return badRequestError("Bad Sort Parameters: %v", err).WithErrorCode(errCodeInvalidSort)
 	}
 
-	permissions, err := models.FindPermissions(a.db, pageParams, sortParams)
+	permissions, err := models.FindPermissions(a.db, r.URL.Query().Get("namespace"), pageParams, sortParams)
 	if err != nil {
-		return internalServerError("Database error finding permissions").WithInternalError(err)
+		return internalServerError("Database error finding permissions").WithErrorCode(errCodeDatabase).WithInternalError(err)
 	}
 	addPaginationHeaders(w, r, pageParams)
 	return sendJSON(w, http.StatusOK, map[string]interface{}{
 		"permissions": permissions,
 	})
 }
+
+type registerPermissionsParams struct {
+	Permissions []models.PermissionDefinition `json:"permissions"`
+}
+
+// PermissionsRegister creates or updates the permissions of a service
+// [POST]/operator/permissions {registerPermissionsParams}
+func (a *API) PermissionsRegister(w http.ResponseWriter, r *http.Request) error {
+	params := &registerPermissionsParams{}
+	if err := decodeParams(r, params); err != nil {
+		return badRequestError("Could not read Permission params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+
+	for i, definition := range params.Permissions {
+		if definition.Name == "" {
+			return unprocessableEntityError("Every permission requires a name").WithErrorCode(errCodeRequired).WithField(fmt.Sprintf("permissions[%d].name", i))
+		}
+	}
+
+	var permissions []models.Permission
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if permissions, terr = models.RegisterPermissions(tx, params.Permissions); terr != nil {
+			return internalServerError("Database error registering permissions").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"permissions": permissions,
+	})
+}
+
+// PermissionDeprecate marks a permission as deprecated
+// [POST]/operator/permissions/{name}/deprecate
+func (a *API) PermissionDeprecate(w http.ResponseWriter, r *http.Request) error {
+	var permission *models.Permission
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if permission, terr = a.getPermissionFromRequest(tx, r); terr != nil {
+			return terr
+		}
+		if terr = permission.Deprecate(tx); terr != nil {
+			return internalServerError("Database error deprecating permission").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, permission)
+}
+
+// PermissionRetire detaches a permission from all roles and hides it
+// [DELETE]/operator/permissions/{name}
+func (a *API) PermissionRetire(w http.ResponseWriter, r *http.Request) error {
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		permission, terr := a.getPermissionFromRequest(tx, r)
+		if terr != nil {
+			return terr
+		}
+		if terr = permission.Retire(tx); terr != nil {
+			return internalServerError("Database error retiring permission").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// cached accounts and roles still contain the permission
+	a.cache.Flush()
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
+
+func (a *API) getPermissionFromRequest(tx *storage.Connection, r *http.Request) (*models.Permission, error) {
+	permission, err := models.FindPermissionByName(tx, chi.URLParam(r, "name"))
+	if err != nil {
+		if _, ok := err.(models.PermissionNotFoundError); ok {
+			return nil, notFoundError(err.Error()).WithErrorCode(errCodePermissionNotFound)
+		}
+		return nil, internalServerError("Database error finding permission").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	return permission, nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/**
+ * Problem Details for HTTP APIs, RFC 7807
+ */
+
+const problemContentType = "application/problem+json"
+
+// problemTypePrefix turns the error code of a problem into its type URI
+const problemTypePrefix = "urn:delivc:team:error:"
+
+// Problem is an HTTPError in the format of RFC 7807,
+// sent instead if the request accepts application/problem+json
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Code     string       `json:"code"`
+	ErrorID  string       `json:"error_id,omitempty"`
+	Errors   []FieldError `json:"errors"`
+}
+
+func newProblem(r *http.Request, e *HTTPError) *Problem {
+	code := e.ErrorCode
+	if code == "" {
+		code = statusErrorCode(e.Code)
+	}
+	problem := &Problem{
+		Type:     problemTypePrefix + code,
+		Title:    http.StatusText(e.Code),
+		Status:   e.Code,
+		Detail:   e.Message,
+		Instance: r.URL.Path,
+		Code:     code,
+		ErrorID:  e.ErrorID,
+		Errors:   []FieldError{},
+	}
+	for _, fieldError := range e.Errors {
+		if fieldError.Code == "" {
+			fieldError.Code = code
+		}
+		problem.Errors = append(problem.Errors, fieldError)
+	}
+	return problem
+}
+
+// statusErrorCode is the code of errors without own code, eg. `not_found`
+func statusErrorCode(status int) string {
+	return strings.ToLower(strings.Replace(http.StatusText(status), " ", "_", -1))
+}
+
+// acceptsProblem checks if the client opted in to problem details
+func acceptsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == problemContentType && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+func sendProblem(w http.ResponseWriter, r *http.Request, e *HTTPError) error {
+	b, err := json.Marshal(newProblem(r, e))
+	if err != nil {
+		return errors.Wrap(err, "Error encoding problem response")
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(e.Code)
+	_, err = w.Write(b)
+	return err
+}
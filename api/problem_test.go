@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptsProblem(t *testing.T) {
+	cases := map[string]bool{
+		"":                         false,
+		"application/json":         false,
+		"application/problem+json": true,
+		"application/json, application/problem+json;q=0.9": true,
+		"application/problem+json;q=0":                     false,
+	}
+	for accept, expected := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/v1/accounts", nil)
+		r.Header.Set("Accept", accept)
+		assert.Equal(t, expected, acceptsProblem(r), accept)
+	}
+}
+
+func TestHandleErrorAsProblem(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/v1/accounts/123", nil)
+	r.Header.Set("Accept", problemContentType)
+	w := httptest.NewRecorder()
+
+	handleError(unprocessableEntityError("Account name can not be empty").WithErrorCode(errCodeRequired).WithField("name"), w, r)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+	problem := &Problem{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), problem))
+	assert.Equal(t, "urn:delivc:team:error:required", problem.Type)
+	assert.Equal(t, "Unprocessable Entity", problem.Title)
+	assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+	assert.Equal(t, "Account name can not be empty", problem.Detail)
+	assert.Equal(t, "/v1/accounts/123", problem.Instance)
+	assert.Equal(t, []FieldError{{Field: "name", Code: errCodeRequired, Message: "Account name can not be empty"}}, problem.Errors)
+}
+
+func TestHandleErrorKeepsDefaultFormat(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/accounts/123", nil)
+	w := httptest.NewRecorder()
+
+	handleError(notFoundError("Account not found").WithErrorCode(errCodeAccountNotFound), w, r)
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"code":404,"msg":"Account not found","error_code":"account_not_found"}`, w.Body.String())
+}
+
+func TestProblemOfUnhandledError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/accounts", nil)
+	r.Header.Set("Accept", problemContentType)
+	w := httptest.NewRecorder()
+
+	handleError(errors.New("connection refused"), w, r)
+
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	problem := &Problem{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), problem))
+	assert.Equal(t, errCodeInternal, problem.Code)
+	assert.Equal(t, "Internal server error", problem.Detail)
+	assert.Empty(t, problem.Errors)
+}
+
+func TestStatusErrorCode(t *testing.T) {
+	assert.Equal(t, "not_found", statusErrorCode(http.StatusNotFound))
+	assert.Equal(t, "unprocessable_entity", statusErrorCode(http.StatusUnprocessableEntity))
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/delivc/team/models"
@@ -23,7 +22,7 @@ func (a *API) RoleGet(w http.ResponseWriter, r *http.Request) error {
 
 	accountID, err = uuid.FromString(chi.URLParam(r, "id"))
 	if err != nil {
-		return badRequestError("Invalid Account ID")
+		return badRequestError("Invalid Account ID").WithErrorCode(errCodeInvalidID)
 	}
 
 	// universal controller
@@ -35,22 +34,43 @@ func (a *API) RoleGet(w http.ResponseWriter, r *http.Request) error {
 		// create uuid from roleIDString
 		roleID, err = uuid.FromString(roleIDString)
 		if err != nil {
-			return badRequestError("Invalid Role ID")
+			return badRequestError("Invalid Role ID").WithErrorCode(errCodeInvalidID)
 		}
 		// check cache before query
 		roleFromCache, exists := a.cache.Get("role-" + roleID.String())
 		if exists {
-			return sendJSON(w, http.StatusOK, roleFromCache)
+			if role, ok := roleFromCache.(*models.Role); ok && role.AccountID == accountID {
+				return sendJSON(w, http.StatusOK, role)
+			}
 		}
 
 		role, err = models.FindRoleByAccountAndID(a.db, accountID, roleID)
 		if err != nil {
-			return internalServerError("Database error finding roles").WithInternalError(err)
+			if models.IsNotFoundError(err) {
+				return notFoundError(err.Error()).WithErrorCode(errCodeRoleNotFound)
+			}
+			return internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
 		}
 		a.cache.SetDefault("role-"+role.ID.String(), role)
 		return sendJSON(w, http.StatusOK, role)
 	}
 
+	pageParams, err := paginate(r)
+	if err != nil {
+		return badRequestError("Bad Pagination Parameters: %v", err).WithErrorCode(errCodeInvalidPage)
+	}
+	if pageParams.Keyset {
+		// single pages are not cached
+		roles, err := models.FindRolesPage(a.db, accountID, pageParams)
+		if err != nil {
+			return internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+		addPaginationHeaders(w, r, pageParams)
+		return sendJSON(w, http.StatusOK, map[string]interface{}{
+			"roles": roles,
+		})
+	}
+
 	rolesFromCache, exists := a.cache.Get("roles-" + accountID.String())
 	if exists {
 		return sendJSON(w, http.StatusOK, map[string]interface{}{
@@ -62,7 +82,7 @@ func (a *API) RoleGet(w http.ResponseWriter, r *http.Request) error {
 	var roles []*models.Role
 	roles, err = models.FindRolesByAccount(a.db, accountID)
 	if err != nil {
-		return internalServerError("Database error finding roles").WithInternalError(err)
+		return internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
 	}
 
 	a.cache.SetDefault("roles-"+accountID.String(), roles)
@@ -73,8 +93,117 @@ func (a *API) RoleGet(w http.ResponseWriter, r *http.Request) error {
 }
 
 type createRoleRequest struct {
-	Name        string   `json:"name"`
-	Permissions []string `json:"permissions"`
+	Name        string   `json:"name" validate:"required,max=255"`
+	Permissions []string `json:"permissions" validate:"unique"`
+	Deny        []string `json:"deny" validate:"unique"`
+	// Conditions restrict when a permission or deny rule applies
+	Conditions map[string]*models.Conditions `json:"conditions"`
+}
+
+// validateConditions checks conditions are valid and
+// only given for permissions of the role
+func (p *createRoleRequest) validateConditions() error {
+	for name, conditions := range p.Conditions {
+		found := false
+		for _, permission := range append(append([]string{}, p.Permissions...), p.Deny...) {
+			if permission == name {
+				found = true
+			}
+		}
+		if !found {
+			return unprocessableEntityError("Conditions given for %v, which is not a permission of the role", name).WithErrorCode(errCodeInvalidConditions).WithField("conditions." + name)
+		}
+		if conditions == nil {
+			continue
+		}
+		if err := conditions.Validate(); err != nil {
+			return unprocessableEntityError("Invalid conditions for %v: %v", name, err).WithErrorCode(errCodeInvalidConditions).WithField("conditions." + name)
+		}
+	}
+	return nil
+}
+
+// hasPermission checks if a permission is granted or denied by the request
+func (p *createRoleRequest) hasPermission(name string) bool {
+	for _, permission := range append(append([]string{}, p.Permissions...), p.Deny...) {
+		if permission == name {
+			return true
+		}
+	}
+	return false
+}
+
+// overlap returns the first permission which is granted and denied at once
+func (p *createRoleRequest) overlap() string {
+	for _, denied := range p.Deny {
+		for _, granted := range p.Permissions {
+			if denied == granted {
+				return denied
+			}
+		}
+	}
+	return ""
+}
+
+// createRole saves a new role of an account with its granted and denied permissions
+func createRole(tx *storage.Connection, accountID uuid.UUID, params *createRoleRequest) (*models.Role, error) {
+	if err := validateRole(tx, accountID, uuid.Nil, params, false); err != nil {
+		return nil, err
+	}
+	role, err := models.NewRole(accountID, params.Name)
+	if err != nil {
+		return nil, internalServerError("Database error creating role").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	if params.Permissions != nil {
+		permissions, err := models.FindPermissionsByName(tx, params.Permissions)
+		if err != nil {
+			return nil, internalServerError("Database error creating role").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+		role.Permissions = permissions
+	}
+
+	if err = tx.Create(role); err != nil {
+		return nil, internalServerError("Database error saving new role").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	if len(params.Deny) > 0 {
+		if err = role.UpdateDeniedPermissions(tx, params.Deny); err != nil {
+			return nil, internalServerError("Database error saving denied permissions").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+	}
+	if len(params.Conditions) > 0 {
+		if err = role.SetConditions(tx, params.Conditions); err != nil {
+			return nil, internalServerError("Database error saving conditions").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+	}
+	return role, nil
+}
+
+// updateRole changes the given parts of a role, missing ones stay as they are
+func updateRole(tx *storage.Connection, role *models.Role, params *createRoleRequest) error {
+	if err := validateRole(tx, role.AccountID, role.ID, params, true); err != nil {
+		return err
+	}
+	if params.Name != "" {
+		if err := role.UpdateName(tx, params.Name); err != nil {
+			return internalServerError("Error during name change").WithErrorCode(errCodeInternal).WithInternalError(err)
+		}
+	}
+	if params.Permissions != nil {
+		if err := role.UpdatePermissions(tx, params.Permissions); err != nil {
+			return internalServerError("Error updating permissions").WithErrorCode(errCodeInternal).WithInternalError(err)
+		}
+	}
+	if params.Deny != nil {
+		if err := role.UpdateDeniedPermissions(tx, params.Deny); err != nil {
+			return internalServerError("Error updating denied permissions").WithErrorCode(errCodeInternal).WithInternalError(err)
+		}
+	}
+	if len(params.Conditions) > 0 {
+		if err := role.SetConditions(tx, params.Conditions); err != nil {
+			return internalServerError("Error updating conditions").WithErrorCode(errCodeInternal).WithInternalError(err)
+		}
+	}
+	return nil
 }
 
 // RoleCreate create a new role with permissions if given
@@ -88,34 +217,25 @@ func (a *API) RoleCreate(w http.ResponseWriter, r *http.Request) error {
 
 	user := getUser(ctx)
 	if user == nil {
-		return badRequestError("Invalid User")
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
 	}
-	if user.IsSuperAdmin || account.IsOwner(user.ID) || account.HasPermissionTo(a.db, "account-role-create", user.ID) {
+	if a.hasPermission(r, account, user, "account-role-create") {
 		params := &createRoleRequest{}
-		jsonDecoder := json.NewDecoder(r.Body)
-		err = jsonDecoder.Decode(params)
+		err = decodeParams(r, params)
 		if err != nil {
-			return badRequestError("Could not read Role Update params: %v", err)
+			return badRequestError("Could not read Role Update params: %v", err).WithErrorCode(errCodeInvalidBody)
+		}
+		if overlap := params.overlap(); overlap != "" {
+			return unprocessableEntityError("Permission %v can not be granted and denied at once", overlap).WithErrorCode(errCodeGrantedAndDenied).WithField("deny")
+		}
+		if err = params.validateConditions(); err != nil {
+			return err
 		}
 		var role *models.Role
 		err = a.db.Transaction(func(conn *storage.Connection) error {
 			var terr error
-			var permissions []models.Permission
-			role, terr = models.NewRole(account.ID, params.Name)
-			if role, terr = models.NewRole(account.ID, params.Name); terr != nil {
-				return internalServerError("Database error creating role").WithInternalError(terr)
-			}
-			if params.Permissions != nil {
-				if permissions, terr = models.FindPermissionsByName(conn, params.Permissions); terr != nil {
-					return internalServerError("Database error creating role").WithInternalError(terr)
-				}
-				role.Permissions = permissions
-			}
-
-			if terr := conn.Create(role); terr != nil {
-				return internalServerError("Database error saving new role").WithInternalError(terr)
-			}
-			return nil
+			role, terr = createRole(conn, account.ID, params)
+			return terr
 		})
 
 		if err != nil {
@@ -127,7 +247,7 @@ func (a *API) RoleCreate(w http.ResponseWriter, r *http.Request) error {
 		return sendJSON(w, 200, role)
 
 	}
-	return unauthorizedError("You dont have `account-role-create` Permission, ask your Manager")
+	return unauthorizedError("You dont have `account-role-create` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
 }
 
 type updateRoleRequest struct {
@@ -140,10 +260,9 @@ func (a *API) RoleUpdate(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
 	params := &updateRoleRequest{}
-	jsonDecoder := json.NewDecoder(r.Body)
-	err := jsonDecoder.Decode(params)
+	err := decodeParams(r, params)
 	if err != nil {
-		return badRequestError("Could not read Account Update params: %v", err)
+		return badRequestError("Could not read Account Update params: %v", err).WithErrorCode(errCodeInvalidBody)
 	}
 
 	account, err := a.getAccountFromRequest(r)
@@ -153,13 +272,13 @@ func (a *API) RoleUpdate(w http.ResponseWriter, r *http.Request) error {
 
 	user := getUser(ctx)
 	if user == nil {
-		return badRequestError("Invalid User")
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
 	}
 
 	// get role from request
 	roleID, err := uuid.FromString(chi.URLParam(r, "roleId"))
 	if err != nil {
-		return badRequestError("Invalid Role ID")
+		return badRequestError("Invalid Role ID").WithErrorCode(errCodeInvalidID)
 	}
 	logrus.Info(roleID)
 
@@ -170,26 +289,39 @@ func (a *API) RoleUpdate(w http.ResponseWriter, r *http.Request) error {
 		role = roleFromCache.(*models.Role)
 	} else {
 		if role, err = models.FindRoleByAccountAndID(a.db, account.ID, roleID); err != nil {
-			return internalServerError("Database error finding roles").WithInternalError(err)
+			if models.IsNotFoundError(err) {
+				return notFoundError(err.Error()).WithErrorCode(errCodeRoleNotFound)
+			}
+			return internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
 		}
 	}
+	if role.AccountID != account.ID {
+		return notFoundError("Role not found").WithErrorCode(errCodeRoleNotFound)
+	}
+	if role.System {
+		return forbiddenError("System roles can not be changed").WithErrorCode(errCodeSystemRole)
+	}
+
+	// compare against the stored permissions, if only one side changes
+	check := params.createRoleRequest
+	if check.Permissions == nil {
+		check.Permissions = role.Permissions.Names()
+	}
+	if check.Deny == nil {
+		check.Deny = role.DeniedPermissions.Names()
+	}
+	if overlap := check.overlap(); overlap != "" {
+		return unprocessableEntityError("Permission %v can not be granted and denied at once", overlap).WithErrorCode(errCodeGrantedAndDenied).WithField("deny")
+	}
+	if err = check.validateConditions(); err != nil {
+		return err
+	}
 
-	if user.IsSuperAdmin || account.IsOwner(user.ID) || account.HasPermissionTo(a.db, "account-role-update", user.ID) {
+	if a.hasPermission(r, account, user, "account-role-update") {
 		// we have permission, now do the updates :)))
 
 		err = a.db.Transaction(func(conn *storage.Connection) error {
-			var terr error
-			if params.Name != "" {
-				if terr = role.UpdateName(conn, params.Name); terr != nil {
-					return internalServerError("Error during name change").WithInternalError(terr)
-				}
-			}
-			if params.Permissions != nil {
-				if terr = role.UpdatePermissions(conn, params.Permissions); terr != nil {
-					return internalServerError("Error updating permissions").WithInternalError(terr)
-				}
-			}
-			return nil
+			return updateRole(conn, role, &params.createRoleRequest)
 		})
 		if err != nil {
 			return err
@@ -198,11 +330,175 @@ func (a *API) RoleUpdate(w http.ResponseWriter, r *http.Request) error {
 		return sendJSON(w, 200, role)
 	}
 
-	return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager")
+	return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+}
+
+// roleDocument holds the attributes of a role a merge patch can change
+type roleDocument struct {
+	Name        string                        `json:"name"`
+	Permissions []string                      `json:"permissions"`
+	Deny        []string                      `json:"deny"`
+	Conditions  map[string]*models.Conditions `json:"conditions"`
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := map[string]bool{}
+	for _, name := range a {
+		names[name] = true
+	}
+	for _, name := range b {
+		if !names[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// RolePatch applies a JSON merge patch to a role, `permissions` and `deny`
+// are replaced as a whole, `conditions` are merged per permission
+// Permission: account-role-update
+// [PATCH]/accounts/{id}/role/{roleId}
+func (a *API) RolePatch(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	roleID, err := uuid.FromString(chi.URLParam(r, "roleId"))
+	if err != nil {
+		return badRequestError("Invalid Role ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	if !a.hasPermission(r, account, user, "account-role-update") {
+		return unauthorizedError("You dont have `account-role-update` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
+	}
+
+	patch, err := readMergePatch(r)
+	if err != nil {
+		return err
+	}
+	if err = checkPatchable(patch, "name", "permissions", "deny", "conditions"); err != nil {
+		return err
+	}
+
+	var role *models.Role
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if role, terr = models.FindRoleByAccountAndID(tx, account.ID, roleID); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return notFoundError(terr.Error()).WithErrorCode(errCodeRoleNotFound)
+			}
+			return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if role.System {
+			return forbiddenError("System roles can not be changed").WithErrorCode(errCodeSystemRole)
+		}
+
+		current := roleDocument{
+			Name:        role.Name,
+			Permissions: role.Permissions.Names(),
+			Deny:        role.DeniedPermissions.Names(),
+			Conditions:  map[string]*models.Conditions{},
+		}
+		for _, permission := range append(append(models.Permissions{}, role.Permissions...), role.DeniedPermissions...) {
+			if permission.Conditions != nil {
+				current.Conditions[permission.Name] = permission.Conditions
+			}
+		}
+		document, terr := toDocument(current)
+		if terr != nil {
+			return internalServerError("Error reading role").WithErrorCode(errCodeInternal).WithInternalError(terr)
+		}
+		patched := roleDocument{}
+		if terr = fromDocument(mergePatch(document, patch), &patched); terr != nil {
+			return unprocessableEntityError("Invalid merge patch: %v", terr).WithErrorCode(errCodeInvalidPatch)
+		}
+
+		// stored conditions of permissions the patch removed go with them
+		patchedConditions, _ := patch["conditions"].(map[string]interface{})
+		check := createRoleRequest{Permissions: patched.Permissions, Deny: patched.Deny, Conditions: map[string]*models.Conditions{}}
+		for name, conditions := range patched.Conditions {
+			_, explicit := patchedConditions[name]
+			if !explicit && !check.hasPermission(name) {
+				continue
+			}
+			check.Conditions[name] = conditions
+		}
+
+		// validate the resulting role before anything is saved,
+		// permissions are only checked if they change
+		permissionsChanged := !sameNames(check.Permissions, current.Permissions)
+		denyChanged := !sameNames(check.Deny, current.Deny)
+		changed := createRoleRequest{Name: patched.Name}
+		if permissionsChanged {
+			changed.Permissions = check.Permissions
+		}
+		if denyChanged {
+			changed.Deny = check.Deny
+		}
+		if terr = validateRole(tx, account.ID, role.ID, &changed, false); terr != nil {
+			return terr
+		}
+		if overlap := check.overlap(); overlap != "" {
+			return unprocessableEntityError("Permission %v can not be granted and denied at once", overlap).WithErrorCode(errCodeGrantedAndDenied).WithField("deny")
+		}
+		if terr = check.validateConditions(); terr != nil {
+			return terr
+		}
+
+		if patched.Name != current.Name {
+			if terr = role.UpdateName(tx, patched.Name); terr != nil {
+				return internalServerError("Error during name change").WithErrorCode(errCodeInternal).WithInternalError(terr)
+			}
+		}
+		// a permission moving between deny and grant is detached from its old effect
+		if permissionsChanged {
+			if terr = role.UpdatePermissions(tx, append([]string{}, check.Permissions...)); terr != nil {
+				return internalServerError("Error updating permissions").WithErrorCode(errCodeInternal).WithInternalError(terr)
+			}
+		}
+		if denyChanged {
+			if terr = role.UpdateDeniedPermissions(tx, check.Deny); terr != nil {
+				return internalServerError("Error updating denied permissions").WithErrorCode(errCodeInternal).WithInternalError(terr)
+			}
+		}
+		if _, ok := patch["conditions"]; ok || permissionsChanged || denyChanged {
+			// replaced permissions lost their conditions, set all of them again
+			conditions := map[string]*models.Conditions{}
+			for _, name := range append(append([]string{}, role.Permissions.Names()...), role.DeniedPermissions.Names()...) {
+				conditions[name] = check.Conditions[name]
+			}
+			if terr = role.SetConditions(tx, conditions); terr != nil {
+				return internalServerError("Error updating conditions").WithErrorCode(errCodeInternal).WithInternalError(terr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.SetDefault("role-"+role.ID.String(), role)
+	a.cache.Delete("roles-" + account.ID.String())
+	a.cache.Delete("account-" + account.ID.String())
+
+	return sendJSON(w, http.StatusOK, role)
 }
 
 // RoleDestroy destroys a role in storage
+// members of the role are moved to the role given in `reassign_to`
 // Permission: account-role-destroy
+// [DELETE]/accounts/{id}/role/{roleId}?reassign_to={roleId}
 func (a *API) RoleDestroy(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 
@@ -213,18 +509,61 @@ func (a *API) RoleDestroy(w http.ResponseWriter, r *http.Request) error {
 
 	user := getUser(ctx)
 	if user == nil {
-		return badRequestError("Invalid User")
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
 	}
 
 	// get role from request
 	roleID, err := uuid.FromString(chi.URLParam(r, "roleId"))
 	if err != nil {
-		return badRequestError("Invalid Role ID")
+		return badRequestError("Invalid Role ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	var reassignTo uuid.UUID
+	if value := r.URL.Query().Get("reassign_to"); value != "" {
+		if reassignTo, err = uuid.FromString(value); err != nil {
+			return badRequestError("Invalid Role ID in reassign_to").WithErrorCode(errCodeInvalidQuery)
+		}
+		if reassignTo == roleID {
+			return unprocessableEntityError("A role can not be reassigned to itself").WithErrorCode(errCodeSelfReassignment)
+		}
 	}
 
-	if user.IsSuperAdmin || account.IsOwner(user.ID) || account.HasPermissionTo(a.db, "account-role-destroy", user.ID) {
+	if a.hasPermission(r, account, user, "account-role-destroy") {
 		err = a.db.Transaction(func(conn *storage.Connection) error {
-			return models.DeleteRole(conn, roleID)
+			role, terr := models.FindRoleByAccountAndID(conn, account.ID, roleID)
+			if terr != nil {
+				if models.IsNotFoundError(terr) {
+					return notFoundError(terr.Error()).WithErrorCode(errCodeRoleNotFound)
+				}
+				return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+			if role.System {
+				return forbiddenError("System roles can not be deleted").WithErrorCode(errCodeSystemRole)
+			}
+
+			members, terr := models.CountRoleMembers(conn, role.ID)
+			if terr != nil {
+				return internalServerError("Database error counting role members").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+			if members > 0 {
+				if reassignTo == uuid.Nil {
+					return conflictError("Role is still in use by %d members, set `reassign_to` to move them to another role", members).WithErrorCode(errCodeRoleInUse)
+				}
+				if _, terr = models.FindRoleByAccountAndID(conn, account.ID, reassignTo); terr != nil {
+					if models.IsNotFoundError(terr) {
+						return unprocessableEntityError("Role in reassign_to does not belong to this account").WithErrorCode(errCodeUnknownRole)
+					}
+					return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+				}
+				if terr = models.ReassignRole(conn, account.ID, role.ID, reassignTo); terr != nil {
+					return internalServerError("Database error reassigning role members").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+				}
+			}
+
+			if terr = models.DeleteRole(conn, role.ID); terr != nil {
+				return internalServerError("Database error deleting role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+			}
+			return nil
 		})
 
 		if err != nil {
@@ -233,11 +572,13 @@ func (a *API) RoleDestroy(w http.ResponseWriter, r *http.Request) error {
 
 		// remove from cache if exists
 		a.cache.Delete("role-" + roleID.String())
+		a.cache.Delete("roles-" + account.ID.String())
+		a.cache.Delete("account-" + account.ID.String())
 
 		return sendJSON(w, http.StatusOK, map[string]interface{}{})
 	}
 
-	return unauthorizedError("You dont have `account-role-destroy` Permission, ask your Manager")
+	return unauthorizedError("You dont have `account-role-destroy` Permission, ask your Manager").WithErrorCode(errCodeMissingPermission)
 }
 
 // detachPermissions
@@ -21,6 +21,12 @@ func (r *router) Route(pattern string, fn func(*router)) {
 	})
 }
 
+func (r *router) Group(fn func(*router)) {
+	r.chi.Group(func(c chi.Router) {
+		fn(&router{c})
+	})
+}
+
 func (r *router) Get(pattern string, fn apiHandler) {
 	r.chi.Get(pattern, handler(fn))
 }
@@ -30,6 +36,9 @@ func (r *router) Post(pattern string, fn apiHandler) {
 func (r *router) Put(pattern string, fn apiHandler) {
 	r.chi.Put(pattern, handler(fn))
 }
+func (r *router) Patch(pattern string, fn apiHandler) {
+	r.chi.Patch(pattern, handler(fn))
+}
 func (r *router) Delete(pattern string, fn apiHandler) {
 	r.chi.Delete(pattern, handler(fn))
 }
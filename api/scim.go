@@ -0,0 +1,341 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * SCIM 2.0 provisioning (RFC 7643, RFC 7644)
+ * Users are members of the account of the SCIM token, Groups are its roles
+ */
+
+const (
+	scimContentType    = "application/scim+json"
+	scimBasePath       = "/" + apiVersion1 + "/scim/v2"
+	scimUserSchema     = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema    = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListSchema     = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema    = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimDefaultCount   = 100
+	scimMaxCount       = 1000
+	scimPatchOpAdd     = "add"
+	scimPatchOpRemove  = "remove"
+	scimPatchOpReplace = "replace"
+)
+
+// SCIMError is an error in the format of RFC 7644
+type SCIMError struct {
+	Schemas       []string `json:"schemas"`
+	Status        string   `json:"status"`
+	ScimType      string   `json:"scimType,omitempty"`
+	Detail        string   `json:"detail"`
+	Code          int      `json:"-"`
+	InternalError error    `json:"-"`
+}
+
+func (e *SCIMError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Detail)
+}
+
+// Cause returns the root cause error
+func (e *SCIMError) Cause() error {
+	if e.InternalError != nil {
+		return e.InternalError
+	}
+	return e
+}
+
+// WithInternalError adds internal error information to the error
+func (e *SCIMError) WithInternalError(err error) *SCIMError {
+	e.InternalError = err
+	return e
+}
+
+func scimError(code int, scimType string, fmtString string, args ...interface{}) *SCIMError {
+	return &SCIMError{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(code),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(fmtString, args...),
+		Code:     code,
+	}
+}
+
+func scimInternalError(fmtString string, args ...interface{}) *SCIMError {
+	return scimError(http.StatusInternalServerError, "", fmtString, args...)
+}
+
+func sendSCIM(w http.ResponseWriter, status int, obj interface{}) error {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return scimInternalError("Error encoding SCIM response").WithInternalError(err)
+	}
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
+
+// requireSCIMToken is a middleware to check if the request
+// is made with the SCIM token of an account
+func (a *API) requireSCIMToken(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	token, err := a.extractBearerToken(w, r)
+	if err != nil {
+		return nil, scimError(http.StatusUnauthorized, "", "This endpoint requires a SCIM token")
+	}
+
+	scimToken, err := models.FindSCIMTokenByToken(a.db, token)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, scimError(http.StatusUnauthorized, "", "Invalid SCIM token")
+		}
+		return nil, scimInternalError("Database error finding SCIM token").WithInternalError(err)
+	}
+
+	return withSCIMToken(r.Context(), scimToken), nil
+}
+
+type scimMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+type scimValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// scimPage reads `startIndex` (1-based) and `count` of a list request
+func scimPage(r *http.Request) (int, int, error) {
+	query := r.URL.Query()
+	startIndex, count := 1, scimDefaultCount
+	var err error
+	if value := query.Get("startIndex"); value != "" {
+		if startIndex, err = strconv.Atoi(value); err != nil {
+			return 0, 0, scimError(http.StatusBadRequest, "invalidValue", "Invalid startIndex '%v'", value)
+		}
+		if startIndex < 1 {
+			startIndex = 1
+		}
+	}
+	if value := query.Get("count"); value != "" {
+		if count, err = strconv.Atoi(value); err != nil {
+			return 0, 0, scimError(http.StatusBadRequest, "invalidValue", "Invalid count '%v'", value)
+		}
+		if count < 0 {
+			count = 0
+		}
+		if count > scimMaxCount {
+			count = scimMaxCount
+		}
+	}
+	return startIndex, count, nil
+}
+
+// scimSlice returns the bounds of a page within total results
+func scimSlice(total, startIndex, count int) (int, int) {
+	from := startIndex - 1
+	if from > total {
+		from = total
+	}
+	to := from + count
+	if to > total {
+		to = total
+	}
+	return from, to
+}
+
+// scimFilter is a parsed `attribute eq "value"` filter,
+// the only filter IdPs rely on for provisioning
+type scimFilter struct {
+	Attribute string
+	Value     string
+}
+
+var scimFilterRegexp = regexp.MustCompile(`^\s*([A-Za-z][\w.]*)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseSCIMFilter parses a filter on one of the given attributes,
+// attribute names are matched case insensitive and returned as given
+func parseSCIMFilter(filter string, attributes ...string) (*scimFilter, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+
+	matches := scimFilterRegexp.FindStringSubmatch(filter)
+	if len(matches) != 3 {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", "Unsupported filter '%v', only `attribute eq \"value\"` is supported", filter)
+	}
+
+	value, err := strconv.Unquote(matches[2])
+	if err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidFilter", "Invalid filter value %v", matches[2])
+	}
+
+	for _, attribute := range attributes {
+		if strings.EqualFold(attribute, matches[1]) {
+			return &scimFilter{Attribute: attribute, Value: value}, nil
+		}
+	}
+	return nil, scimError(http.StatusBadRequest, "invalidFilter", "Filtering by '%v' is not supported", matches[1])
+}
+
+type scimPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func readSCIMPatch(r *http.Request) (*scimPatchRequest, error) {
+	params := &scimPatchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		return nil, scimError(http.StatusBadRequest, "invalidSyntax", "Could not read PATCH operations: %v", err)
+	}
+	for i, op := range params.Operations {
+		op.Op = strings.ToLower(op.Op)
+		switch op.Op {
+		case scimPatchOpAdd, scimPatchOpRemove, scimPatchOpReplace:
+		default:
+			return nil, scimError(http.StatusBadRequest, "invalidSyntax", "Unsupported PATCH operation '%v'", op.Op)
+		}
+		params.Operations[i] = op
+	}
+	return params, nil
+}
+
+// scimBool reads a boolean which some IdPs send as string
+func scimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
+
+/**
+ * SCIM token of an account
+ */
+
+type createSCIMTokenParams struct {
+	RoleID uuid.UUID `json:"role_id" validate:"required"`
+}
+
+// SCIMTokenCreate creates a new SCIM token for an account, replacing the old one.
+// The plain token is only returned once
+// User MUST be SuperAdmin or Owner
+// [POST]/accounts/{id}/scim-token
+func (a *API) SCIMTokenCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !(user.IsSuperAdmin || account.IsOwner(user.ID)) {
+		return unauthorizedError("You dont have proper permission").WithErrorCode(errCodeMissingPermission)
+	}
+
+	params := &createSCIMTokenParams{}
+	if err = decodeParams(r, params); err != nil {
+		return badRequestError("Could not read SCIM Token params: %v", err).WithErrorCode(errCodeInvalidBody)
+	}
+	if err = validateParams(params, false).err(); err != nil {
+		return err
+	}
+
+	var scimToken *models.SCIMToken
+	var token string
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if _, terr = models.FindRoleByAccountAndID(tx, account.ID, params.RoleID); terr != nil {
+			if models.IsNotFoundError(terr) {
+				return unprocessableEntityError("Role does not belong to this account").WithErrorCode(errCodeUnknownRole)
+			}
+			return internalServerError("Database error finding role").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+
+		if terr = models.DeleteSCIMTokensOfAccount(tx, account.ID); terr != nil {
+			return internalServerError("Database error revoking SCIM token").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if scimToken, token, terr = models.NewSCIMToken(account.ID, params.RoleID, user.ID); terr != nil {
+			return internalServerError("Database error creating SCIM token").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		if terr = tx.Create(scimToken); terr != nil {
+			return internalServerError("Database error saving new SCIM token").WithErrorCode(errCodeDatabase).WithInternalError(terr)
+		}
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), account.ID, user.ID, uuid.Nil, models.SCIMTokenCreatedAction, map[string]interface{}{
+			"role_id": params.RoleID,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusCreated, map[string]interface{}{
+		"token":      token,
+		"scim_token": scimToken,
+	})
+}
+
+// SCIMTokenDestroy revokes the SCIM token of an account
+// User MUST be SuperAdmin or Owner
+// [DELETE]/accounts/{id}/scim-token
+func (a *API) SCIMTokenDestroy(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	account, err := a.getAccountFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	user := getUser(ctx)
+	if user == nil {
+		return badRequestError("Invalid User").WithErrorCode(errCodeInvalidUser)
+	}
+
+	if !(user.IsSuperAdmin || account.IsOwner(user.ID)) {
+		return unauthorizedError("You dont have proper permission").WithErrorCode(errCodeMissingPermission)
+	}
+
+	if err = models.DeleteSCIMTokensOfAccount(a.db, account.ID); err != nil {
+		return internalServerError("Database error revoking SCIM token").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{})
+}
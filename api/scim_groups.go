@@ -0,0 +1,448 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * SCIM Groups, the roles of an account.
+ * A member has a single role, adding a user to a group moves it out of its
+ * previous group, removing it moves it to the default role of the SCIM token
+ */
+
+type scimGroup struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	DisplayName string      `json:"displayName"`
+	Members     []scimValue `json:"members"`
+	Meta        scimMeta    `json:"meta"`
+}
+
+type scimGroupParams struct {
+	DisplayName string      `json:"displayName"`
+	Members     []scimValue `json:"members"`
+}
+
+var scimMembersPathRegexp = regexp.MustCompile(`^(?i:members)\[(.*)\]$`)
+
+func (a *API) toSCIMGroup(role *models.Role, users []*models.SCIMUser) *scimGroup {
+	members := []scimValue{}
+	for _, user := range users {
+		if user.RoleID == role.ID {
+			members = append(members, scimValue{Value: user.ID.String(), Display: user.UserName})
+		}
+	}
+
+	return &scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          role.ID.String(),
+		DisplayName: role.Name,
+		Members:     members,
+		Meta: scimMeta{
+			ResourceType: "Group",
+			Created:      role.CreatedAt,
+			LastModified: role.UpdatedAt,
+			Location:     scimBasePath + "/Groups/" + role.ID.String(),
+		},
+	}
+}
+
+func scimMemberIDs(values []scimValue) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for _, value := range values {
+		id, err := uuid.FromString(value.Value)
+		if err != nil {
+			return nil, scimError(http.StatusBadRequest, "invalidValue", "Invalid member '%v'", value.Value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (a *API) getSCIMGroupFromRequest(tx *storage.Connection, r *http.Request) (*models.Role, error) {
+	token := getSCIMToken(r.Context())
+	roleID, err := uuid.FromString(chi.URLParam(r, "groupId"))
+	if err != nil {
+		return nil, scimError(http.StatusNotFound, "", "Group not found")
+	}
+
+	role, err := models.FindRoleByAccountAndID(tx, token.AccountID, roleID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, scimError(http.StatusNotFound, "", "Group not found")
+		}
+		return nil, scimInternalError("Database error finding group").WithInternalError(err)
+	}
+	return role, nil
+}
+
+// renameSCIMGroup changes the name of a role, names are unique within an account
+func (a *API) renameSCIMGroup(tx *storage.Connection, role *models.Role, name string) error {
+	if name == "" || name == role.Name {
+		return nil
+	}
+	if role.System {
+		return scimError(http.StatusBadRequest, "mutability", "System groups can not be renamed")
+	}
+	if err := a.checkSCIMGroupName(tx, role.AccountID, name); err != nil {
+		return err
+	}
+	if err := role.UpdateName(tx, name); err != nil {
+		return scimInternalError("Database error renaming group").WithInternalError(err)
+	}
+	return nil
+}
+
+func (a *API) checkSCIMGroupName(tx *storage.Connection, accountID uuid.UUID, name string) error {
+	roles, err := models.FindRolesByAccount(tx, accountID)
+	if err != nil {
+		return scimInternalError("Database error finding groups").WithInternalError(err)
+	}
+	for _, existing := range roles {
+		if strings.EqualFold(existing.Name, name) {
+			return scimError(http.StatusConflict, "uniqueness", "Group '%v' already exists", name)
+		}
+	}
+	return nil
+}
+
+// addSCIMGroupMembers moves the given users into the group
+func (a *API) addSCIMGroupMembers(tx *storage.Connection, role *models.Role, ids []uuid.UUID) error {
+	for _, id := range ids {
+		user, err := models.FindSCIMUserByAccountAndID(tx, role.AccountID, id)
+		if err != nil {
+			if models.IsNotFoundError(err) {
+				return scimError(http.StatusBadRequest, "invalidValue", "Member '%v' not found", id)
+			}
+			return scimInternalError("Database error finding user").WithInternalError(err)
+		}
+		if err = user.UpdateRole(tx, role.ID); err != nil {
+			return scimInternalError("Database error adding member").WithInternalError(err)
+		}
+	}
+	return nil
+}
+
+// removeSCIMGroupMembers moves the given users of the group to the default role,
+// nil removes all members
+func (a *API) removeSCIMGroupMembers(tx *storage.Connection, token *models.SCIMToken, role *models.Role, ids []uuid.UUID) error {
+	users, err := models.FindSCIMUsers(tx, role.AccountID)
+	if err != nil {
+		return scimInternalError("Database error finding users").WithInternalError(err)
+	}
+
+	remove := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		remove[id] = true
+	}
+	for _, user := range users {
+		if user.RoleID != role.ID || (ids != nil && !remove[user.ID]) {
+			continue
+		}
+		if err = user.UpdateRole(tx, token.RoleID); err != nil {
+			return scimInternalError("Database error removing member").WithInternalError(err)
+		}
+	}
+	return nil
+}
+
+func (a *API) sendSCIMGroup(w http.ResponseWriter, status int, role *models.Role) error {
+	users, err := models.FindSCIMUsers(a.db, role.AccountID)
+	if err != nil {
+		return scimInternalError("Database error finding users").WithInternalError(err)
+	}
+	return sendSCIM(w, status, a.toSCIMGroup(role, users))
+}
+
+func (a *API) invalidateSCIMGroup(role *models.Role) {
+	a.cache.Delete("account-" + role.AccountID.String())
+	a.cache.Delete("roles-" + role.AccountID.String())
+	a.cache.Delete("role-" + role.ID.String())
+}
+
+// SCIMGroupsGet returns the roles of the account, filterable by displayName
+// [GET]/scim/v2/Groups
+func (a *API) SCIMGroupsGet(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	filter, err := parseSCIMFilter(r.URL.Query().Get("filter"), "displayName")
+	if err != nil {
+		return err
+	}
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		return err
+	}
+
+	roles, err := models.FindRolesByAccount(a.db, token.AccountID)
+	if err != nil {
+		return scimInternalError("Database error finding groups").WithInternalError(err)
+	}
+	users, err := models.FindSCIMUsers(a.db, token.AccountID)
+	if err != nil {
+		return scimInternalError("Database error finding users").WithInternalError(err)
+	}
+
+	resources := []*scimGroup{}
+	for _, role := range roles {
+		if filter != nil && !strings.EqualFold(role.Name, filter.Value) {
+			continue
+		}
+		resources = append(resources, a.toSCIMGroup(role, users))
+	}
+
+	from, to := scimSlice(len(resources), startIndex, count)
+	return sendSCIM(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: to - from,
+		Resources:    resources[from:to],
+	})
+}
+
+// SCIMGroupGet returns a single role of the account
+// [GET]/scim/v2/Groups/{groupId}
+func (a *API) SCIMGroupGet(w http.ResponseWriter, r *http.Request) error {
+	role, err := a.getSCIMGroupFromRequest(a.db, r)
+	if err != nil {
+		return err
+	}
+	return a.sendSCIMGroup(w, http.StatusOK, role)
+}
+
+// SCIMGroupCreate creates a role without permissions, permissions are
+// managed within Team
+// [POST]/scim/v2/Groups
+func (a *API) SCIMGroupCreate(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	params := &scimGroupParams{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "Could not read Group: %v", err)
+	}
+	if strings.TrimSpace(params.DisplayName) == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "displayName is required")
+	}
+	ids, err := scimMemberIDs(params.Members)
+	if err != nil {
+		return err
+	}
+
+	var role *models.Role
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if terr = a.checkSCIMGroupName(tx, token.AccountID, params.DisplayName); terr != nil {
+			return terr
+		}
+		if role, terr = models.NewRole(token.AccountID, params.DisplayName); terr != nil {
+			return scimInternalError("Error creating group").WithInternalError(terr)
+		}
+		if terr = tx.Create(role); terr != nil {
+			return scimInternalError("Database error saving new group").WithInternalError(terr)
+		}
+		return a.addSCIMGroupMembers(tx, role, ids)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidateSCIMGroup(role)
+	return a.sendSCIMGroup(w, http.StatusCreated, role)
+}
+
+// SCIMGroupReplace renames a role and replaces its provisioned members
+// [PUT]/scim/v2/Groups/{groupId}
+func (a *API) SCIMGroupReplace(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	params := &scimGroupParams{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "Could not read Group: %v", err)
+	}
+	ids, err := scimMemberIDs(params.Members)
+	if err != nil {
+		return err
+	}
+
+	var role *models.Role
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if role, terr = a.getSCIMGroupFromRequest(tx, r); terr != nil {
+			return terr
+		}
+		if terr = a.renameSCIMGroup(tx, role, params.DisplayName); terr != nil {
+			return terr
+		}
+		if terr = a.removeSCIMGroupMembers(tx, token, role, nil); terr != nil {
+			return terr
+		}
+		return a.addSCIMGroupMembers(tx, role, ids)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidateSCIMGroup(role)
+	return a.sendSCIMGroup(w, http.StatusOK, role)
+}
+
+// SCIMGroupPatch renames a role or adds and removes provisioned members
+// [PATCH]/scim/v2/Groups/{groupId}
+func (a *API) SCIMGroupPatch(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	params, err := readSCIMPatch(r)
+	if err != nil {
+		return err
+	}
+
+	var role *models.Role
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if role, terr = a.getSCIMGroupFromRequest(tx, r); terr != nil {
+			return terr
+		}
+		for _, op := range params.Operations {
+			if terr = a.applySCIMGroupOperation(tx, token, role, op); terr != nil {
+				return terr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidateSCIMGroup(role)
+	return a.sendSCIMGroup(w, http.StatusOK, role)
+}
+
+func (a *API) applySCIMGroupOperation(tx *storage.Connection, token *models.SCIMToken, role *models.Role, op scimPatchOperation) error {
+	path := strings.TrimSpace(op.Path)
+
+	// `members[value eq "id"]` targets a single member
+	if matches := scimMembersPathRegexp.FindStringSubmatch(path); len(matches) == 2 {
+		filter, err := parseSCIMFilter(matches[1], "value")
+		if err != nil {
+			return err
+		}
+		if op.Op != scimPatchOpRemove {
+			return scimError(http.StatusBadRequest, "invalidPath", "Only remove is supported for '%v'", path)
+		}
+		ids, err := scimMemberIDs([]scimValue{{Value: filter.Value}})
+		if err != nil {
+			return err
+		}
+		return a.removeSCIMGroupMembers(tx, token, role, ids)
+	}
+
+	switch strings.ToLower(path) {
+	case "":
+		if op.Op == scimPatchOpRemove {
+			return scimError(http.StatusBadRequest, "noTarget", "remove requires a path")
+		}
+		params := &scimGroupParams{}
+		if err := json.Unmarshal(op.Value, params); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "Value of a PATCH without path must be an object")
+		}
+		if err := a.renameSCIMGroup(tx, role, params.DisplayName); err != nil {
+			return err
+		}
+		if params.Members == nil {
+			return nil
+		}
+		members, err := json.Marshal(params.Members)
+		if err != nil {
+			return scimInternalError("Error reading members").WithInternalError(err)
+		}
+		return a.applySCIMGroupOperation(tx, token, role, scimPatchOperation{Op: op.Op, Path: "members", Value: members})
+	case "displayname":
+		if op.Op == scimPatchOpRemove {
+			return scimError(http.StatusBadRequest, "mutability", "displayName is required")
+		}
+		var name string
+		if err := json.Unmarshal(op.Value, &name); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "Invalid value for displayName")
+		}
+		return a.renameSCIMGroup(tx, role, name)
+	case "members":
+		values := []scimValue{}
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return scimError(http.StatusBadRequest, "invalidValue", "Invalid value for members")
+			}
+		}
+		ids, err := scimMemberIDs(values)
+		if err != nil {
+			return err
+		}
+		switch op.Op {
+		case scimPatchOpAdd:
+			return a.addSCIMGroupMembers(tx, role, ids)
+		case scimPatchOpReplace:
+			if err = a.removeSCIMGroupMembers(tx, token, role, nil); err != nil {
+				return err
+			}
+			return a.addSCIMGroupMembers(tx, role, ids)
+		default:
+			// remove without a value removes all members
+			if len(values) == 0 {
+				ids = nil
+			}
+			return a.removeSCIMGroupMembers(tx, token, role, ids)
+		}
+	}
+	return scimError(http.StatusBadRequest, "invalidPath", "Unsupported path '%v'", path)
+}
+
+// SCIMGroupDestroy deletes a role, its provisioned members move to the
+// default role of the SCIM token. Roles with other members are kept
+// [DELETE]/scim/v2/Groups/{groupId}
+func (a *API) SCIMGroupDestroy(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	var role *models.Role
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if role, terr = a.getSCIMGroupFromRequest(tx, r); terr != nil {
+			return terr
+		}
+		if role.System {
+			return scimError(http.StatusBadRequest, "mutability", "System groups can not be deleted")
+		}
+		if role.ID == token.RoleID {
+			return scimError(http.StatusConflict, "", "The default role of the SCIM token can not be deleted")
+		}
+		if terr = a.removeSCIMGroupMembers(tx, token, role, nil); terr != nil {
+			return terr
+		}
+
+		count, terr := models.CountRoleMembers(tx, role.ID)
+		if terr != nil {
+			return scimInternalError("Database error counting members").WithInternalError(terr)
+		}
+		if count > 0 {
+			return scimError(http.StatusConflict, "", "Group still has members which are not provisioned")
+		}
+		if terr = models.DeleteRole(tx, role.ID); terr != nil {
+			return scimInternalError("Database error deleting group").WithInternalError(terr)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.invalidateSCIMGroup(role)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	filter, err := parseSCIMFilter(`username EQ "jane@acme.com"`, "userName", "externalId")
+	require.NoError(t, err)
+	assert.Equal(t, "userName", filter.Attribute)
+	assert.Equal(t, "jane@acme.com", filter.Value)
+
+	filter, err = parseSCIMFilter(`displayName eq "say \"hi\""`, "displayName")
+	require.NoError(t, err)
+	assert.Equal(t, `say "hi"`, filter.Value)
+
+	filter, err = parseSCIMFilter("", "userName")
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	for _, invalid := range []string{
+		`userName co "jane"`,
+		`userName eq jane`,
+		`userName eq "jane" and active eq "true"`,
+		`title eq "boss"`,
+	} {
+		_, err = parseSCIMFilter(invalid, "userName")
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSCIMSlice(t *testing.T) {
+	from, to := scimSlice(5, 1, 2)
+	assert.Equal(t, []int{0, 2}, []int{from, to})
+
+	from, to = scimSlice(5, 4, 10)
+	assert.Equal(t, []int{3, 5}, []int{from, to})
+
+	from, to = scimSlice(5, 9, 10)
+	assert.Equal(t, []int{5, 5}, []int{from, to})
+}
@@ -0,0 +1,469 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	identity "github.com/delivc/identity/models"
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * SCIM Users, the members of an account
+ */
+
+type scimUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Active      bool        `json:"active"`
+	Emails      []scimValue `json:"emails"`
+	Groups      []scimValue `json:"groups"`
+	Meta        scimMeta    `json:"meta"`
+}
+
+type scimUserParams struct {
+	ExternalID  string `json:"externalId"`
+	UserName    string `json:"userName"`
+	DisplayName string `json:"displayName"`
+	Name        struct {
+		Formatted string `json:"formatted"`
+	} `json:"name"`
+	Active json.RawMessage `json:"active"`
+	Emails []scimValue     `json:"emails"`
+}
+
+// apply copies the attributes of a POST or PUT onto the provisioned user
+func (p *scimUserParams) apply(u *models.SCIMUser) error {
+	userName := p.UserName
+	if userName == "" {
+		for _, email := range p.Emails {
+			if email.Primary || userName == "" {
+				userName = email.Value
+			}
+		}
+	}
+	if strings.TrimSpace(userName) == "" {
+		return scimError(http.StatusBadRequest, "invalidValue", "userName is required")
+	}
+
+	u.UserName = strings.ToLower(strings.TrimSpace(userName))
+	u.ExternalID = p.ExternalID
+	u.DisplayName = p.DisplayName
+	if u.DisplayName == "" {
+		u.DisplayName = p.Name.Formatted
+	}
+	u.Active = true
+	if len(p.Active) > 0 {
+		active, err := scimBool(p.Active)
+		if err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "Invalid value for active")
+		}
+		u.Active = active
+	}
+	return nil
+}
+
+// applySCIMUserAttribute changes a single attribute of a provisioned user,
+// attributes which Team does not store are ignored
+func applySCIMUserAttribute(u *models.SCIMUser, op, attribute string, value json.RawMessage) error {
+	remove := op == scimPatchOpRemove
+	var s string
+	if !remove && strings.ToLower(attribute) != "active" {
+		if err := json.Unmarshal(value, &s); err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "Invalid value for %v", attribute)
+		}
+	}
+
+	switch strings.ToLower(attribute) {
+	case "active":
+		if remove {
+			u.Active = false
+			return nil
+		}
+		active, err := scimBool(value)
+		if err != nil {
+			return scimError(http.StatusBadRequest, "invalidValue", "Invalid value for active")
+		}
+		u.Active = active
+	case "username":
+		if remove || strings.TrimSpace(s) == "" {
+			return scimError(http.StatusBadRequest, "mutability", "userName is required")
+		}
+		u.UserName = strings.ToLower(strings.TrimSpace(s))
+	case "externalid":
+		u.ExternalID = s
+	case "displayname":
+		u.DisplayName = s
+	}
+	return nil
+}
+
+func (a *API) toSCIMUser(u *models.SCIMUser, roles map[uuid.UUID]*models.Role) *scimUser {
+	groups := []scimValue{}
+	if role, ok := roles[u.RoleID]; ok {
+		groups = append(groups, scimValue{Value: role.ID.String(), Display: role.Name})
+	}
+
+	return &scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          u.ID.String(),
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.DisplayName,
+		Active:      u.Active,
+		Emails:      []scimValue{{Value: u.UserName, Primary: true}},
+		Groups:      groups,
+		Meta: scimMeta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     scimBasePath + "/Users/" + u.ID.String(),
+		},
+	}
+}
+
+func (a *API) scimRolesByID(tx *storage.Connection, accountID uuid.UUID) (map[uuid.UUID]*models.Role, error) {
+	roles, err := models.FindRolesByAccount(tx, accountID)
+	if err != nil {
+		return nil, scimInternalError("Database error finding groups").WithInternalError(err)
+	}
+	byID := map[uuid.UUID]*models.Role{}
+	for _, role := range roles {
+		byID[role.ID] = role
+	}
+	return byID, nil
+}
+
+func (a *API) getSCIMUserFromRequest(tx *storage.Connection, r *http.Request) (*models.SCIMUser, error) {
+	token := getSCIMToken(r.Context())
+	userID, err := uuid.FromString(chi.URLParam(r, "userId"))
+	if err != nil {
+		return nil, scimError(http.StatusNotFound, "", "User not found")
+	}
+
+	user, err := models.FindSCIMUserByAccountAndID(tx, token.AccountID, userID)
+	if err != nil {
+		if models.IsNotFoundError(err) {
+			return nil, scimError(http.StatusNotFound, "", err.Error())
+		}
+		return nil, scimInternalError("Database error finding user").WithInternalError(err)
+	}
+	return user, nil
+}
+
+// saveSCIMUser stores the provisioned user and syncs the membership of its identity user
+func (a *API) saveSCIMUser(tx *storage.Connection, u *models.SCIMUser) error {
+	if existing, err := models.FindSCIMUserByUserName(tx, u.AccountID, u.UserName); err == nil {
+		if existing.ID != u.ID {
+			return scimError(http.StatusConflict, "uniqueness", "User '%v' already exists", u.UserName)
+		}
+	} else if !models.IsNotFoundError(err) {
+		return scimInternalError("Database error finding user").WithInternalError(err)
+	}
+
+	if err := tx.Update(u); err != nil {
+		return scimInternalError("Database error saving user").WithInternalError(err)
+	}
+	if err := u.SyncMembership(tx); err != nil {
+		return scimInternalError("Database error syncing membership").WithInternalError(err)
+	}
+	return nil
+}
+
+// SCIMUsersGet returns the provisioned users of the account,
+// filterable by userName, externalId and displayName
+// [GET]/scim/v2/Users
+func (a *API) SCIMUsersGet(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	filter, err := parseSCIMFilter(r.URL.Query().Get("filter"), "userName", "externalId", "displayName")
+	if err != nil {
+		return err
+	}
+	startIndex, count, err := scimPage(r)
+	if err != nil {
+		return err
+	}
+
+	users, err := models.FindSCIMUsers(a.db, token.AccountID)
+	if err != nil {
+		return scimInternalError("Database error finding users").WithInternalError(err)
+	}
+	roles, err := a.scimRolesByID(a.db, token.AccountID)
+	if err != nil {
+		return err
+	}
+
+	resources := []*scimUser{}
+	for _, user := range users {
+		if filter != nil {
+			value := map[string]string{
+				"userName":    user.UserName,
+				"externalId":  user.ExternalID,
+				"displayName": user.DisplayName,
+			}[filter.Attribute]
+			if !strings.EqualFold(value, filter.Value) {
+				continue
+			}
+		}
+		resources = append(resources, a.toSCIMUser(user, roles))
+	}
+
+	from, to := scimSlice(len(resources), startIndex, count)
+	return sendSCIM(w, http.StatusOK, &scimListResponse{
+		Schemas:      []string{scimListSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: to - from,
+		Resources:    resources[from:to],
+	})
+}
+
+// SCIMUserGet returns a single provisioned user
+// [GET]/scim/v2/Users/{userId}
+func (a *API) SCIMUserGet(w http.ResponseWriter, r *http.Request) error {
+	token := getSCIMToken(r.Context())
+
+	user, err := a.getSCIMUserFromRequest(a.db, r)
+	if err != nil {
+		return err
+	}
+	roles, err := a.scimRolesByID(a.db, token.AccountID)
+	if err != nil {
+		return err
+	}
+
+	return sendSCIM(w, http.StatusOK, a.toSCIMUser(user, roles))
+}
+
+// SCIMUserCreate provisions a user, it joins the account with the default role
+// of the SCIM token once its identity user authenticates with the same email
+// [POST]/scim/v2/Users
+func (a *API) SCIMUserCreate(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	token := getSCIMToken(ctx)
+
+	params := &scimUserParams{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "Could not read User: %v", err)
+	}
+
+	user, err := models.NewSCIMUser(token.AccountID, token.RoleID, params.UserName)
+	if err != nil {
+		return scimInternalError("Error creating user").WithInternalError(err)
+	}
+	if err = params.apply(user); err != nil {
+		return err
+	}
+
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		if _, terr := models.FindSCIMUserByUserName(tx, token.AccountID, user.UserName); terr == nil {
+			return scimError(http.StatusConflict, "uniqueness", "User '%v' already exists", user.UserName)
+		} else if !models.IsNotFoundError(terr) {
+			return scimInternalError("Database error finding user").WithInternalError(terr)
+		}
+
+		if terr := tx.Create(user); terr != nil {
+			return scimInternalError("Database error saving new user").WithInternalError(terr)
+		}
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), token.AccountID, uuid.Nil, uuid.Nil, models.SCIMUserProvisionedAction, map[string]interface{}{
+			"scim_user_id": user.ID,
+			"user_name":    user.UserName,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	roles, err := a.scimRolesByID(a.db, token.AccountID)
+	if err != nil {
+		return err
+	}
+	return sendSCIM(w, http.StatusCreated, a.toSCIMUser(user, roles))
+}
+
+// SCIMUserReplace replaces all attributes of a provisioned user
+// [PUT]/scim/v2/Users/{userId}
+func (a *API) SCIMUserReplace(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	token := getSCIMToken(ctx)
+
+	params := &scimUserParams{}
+	if err := json.NewDecoder(r.Body).Decode(params); err != nil {
+		return scimError(http.StatusBadRequest, "invalidSyntax", "Could not read User: %v", err)
+	}
+
+	var user *models.SCIMUser
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if user, terr = a.getSCIMUserFromRequest(tx, r); terr != nil {
+			return terr
+		}
+		if terr = params.apply(user); terr != nil {
+			return terr
+		}
+		if terr = a.saveSCIMUser(tx, user); terr != nil {
+			return terr
+		}
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), token.AccountID, uuid.Nil, uuid.Nil, models.SCIMUserUpdatedAction, map[string]interface{}{
+			"scim_user_id": user.ID,
+			"active":       user.Active,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + token.AccountID.String())
+
+	roles, err := a.scimRolesByID(a.db, token.AccountID)
+	if err != nil {
+		return err
+	}
+	return sendSCIM(w, http.StatusOK, a.toSCIMUser(user, roles))
+}
+
+// SCIMUserPatch changes single attributes of a provisioned user,
+// deactivating a user removes the membership of its identity user
+// [PATCH]/scim/v2/Users/{userId}
+func (a *API) SCIMUserPatch(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	token := getSCIMToken(ctx)
+
+	params, err := readSCIMPatch(r)
+	if err != nil {
+		return err
+	}
+
+	var user *models.SCIMUser
+	err = a.db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		if user, terr = a.getSCIMUserFromRequest(tx, r); terr != nil {
+			return terr
+		}
+
+		for _, op := range params.Operations {
+			if op.Path != "" {
+				if terr = applySCIMUserAttribute(user, op.Op, op.Path, op.Value); terr != nil {
+					return terr
+				}
+				continue
+			}
+			if op.Op == scimPatchOpRemove {
+				return scimError(http.StatusBadRequest, "noTarget", "remove requires a path")
+			}
+			values := map[string]json.RawMessage{}
+			if terr = json.Unmarshal(op.Value, &values); terr != nil {
+				return scimError(http.StatusBadRequest, "invalidValue", "Value of a PATCH without path must be an object")
+			}
+			for attribute, value := range values {
+				if terr = applySCIMUserAttribute(user, op.Op, attribute, value); terr != nil {
+					return terr
+				}
+			}
+		}
+
+		if terr = a.saveSCIMUser(tx, user); terr != nil {
+			return terr
+		}
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), token.AccountID, uuid.Nil, uuid.Nil, models.SCIMUserUpdatedAction, map[string]interface{}{
+			"scim_user_id": user.ID,
+			"active":       user.Active,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + token.AccountID.String())
+
+	roles, err := a.scimRolesByID(a.db, token.AccountID)
+	if err != nil {
+		return err
+	}
+	return sendSCIM(w, http.StatusOK, a.toSCIMUser(user, roles))
+}
+
+// SCIMUserDestroy deprovisions a user and removes the membership of its identity user
+// [DELETE]/scim/v2/Users/{userId}
+func (a *API) SCIMUserDestroy(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	token := getSCIMToken(ctx)
+
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		user, terr := a.getSCIMUserFromRequest(tx, r)
+		if terr != nil {
+			return terr
+		}
+		if terr = models.DeleteSCIMUser(tx, user); terr != nil {
+			return scimInternalError("Database error deleting user").WithInternalError(terr)
+		}
+		subjectID := uuid.Nil
+		if user.UserID != nil {
+			subjectID = *user.UserID
+		}
+		return models.NewAuditLogEntry(tx, getInstanceID(ctx), token.AccountID, uuid.Nil, subjectID, models.SCIMUserDeprovisionedAction, map[string]interface{}{
+			"scim_user_id": user.ID,
+			"user_name":    user.UserName,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	a.cache.Delete("account-" + token.AccountID.String())
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// linkProvisionedUsers links the users provisioned with the confirmed email
+// of the identity user, which makes it a member of their accounts if the domain
+// of the email is verified by the account and invites it otherwise
+func (a *API) linkProvisionedUsers(ctx context.Context, user *identity.User) error {
+	if user.ConfirmedAt == nil || user.Email == "" {
+		return nil
+	}
+
+	linked := []*models.SCIMUser{}
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		provisioned, terr := models.FindUnlinkedSCIMUsers(tx, user.Email)
+		if terr != nil {
+			return terr
+		}
+		for _, scimUser := range provisioned {
+			if terr = scimUser.Link(tx, user.ID); terr != nil {
+				return terr
+			}
+			verified, terr := models.IsEmailDomainVerified(tx, scimUser.AccountID, scimUser.UserName)
+			if terr != nil {
+				return terr
+			}
+			if terr = models.NewAuditLogEntry(tx, getInstanceID(ctx), scimUser.AccountID, uuid.Nil, user.ID, models.SCIMUserLinkedAction, map[string]interface{}{
+				"scim_user_id": scimUser.ID,
+				"role_id":      scimUser.RoleID,
+				"confirmed":    verified,
+			}); terr != nil {
+				return terr
+			}
+			linked = append(linked, scimUser)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, scimUser := range linked {
+		a.cache.Delete("account-" + scimUser.AccountID.String())
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"time"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const expirySweepInterval = time.Minute
+
+// sweepExpiredAccess removes memberships and role assignments
+// which have run out, until done is closed
+func (a *API) sweepExpiredAccess(log logrus.FieldLogger, done <-chan struct{}) {
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.deleteExpiredAccess(time.Now()); err != nil {
+				log.WithError(err).Error("Error removing expired access")
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func (a *API) deleteExpiredAccess(now time.Time) error {
+	accountIDs := map[uuid.UUID]bool{}
+	err := a.db.Transaction(func(tx *storage.Connection) error {
+		members, terr := models.DeleteExpiredAccountUsers(tx, now)
+		if terr != nil {
+			return terr
+		}
+		for _, member := range members {
+			accountIDs[member.AccountID] = true
+			// resource assignments end with the membership
+			if terr = models.DeleteRoleAssignmentsOfUser(tx, member.AccountID, member.UserID); terr != nil {
+				return terr
+			}
+			if terr = models.NewAuditLogEntry(tx, uuid.Nil, member.AccountID, uuid.Nil, member.UserID, models.MembershipExpiredAction, map[string]interface{}{
+				"role_id":    member.RoleID,
+				"expires_at": member.ExpiresAt,
+			}); terr != nil {
+				return terr
+			}
+		}
+
+		assignments, terr := models.DeleteExpiredRoleAssignments(tx, now)
+		if terr != nil {
+			return terr
+		}
+		for _, assignment := range assignments {
+			if terr = models.NewAuditLogEntry(tx, uuid.Nil, assignment.AccountID, uuid.Nil, assignment.UserID, models.RoleAssignmentExpiredAction, map[string]interface{}{
+				"role_id":    assignment.RoleID,
+				"resource":   assignment.Resource().String(),
+				"expires_at": assignment.ExpiresAt,
+			}); terr != nil {
+				return terr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for accountID := range accountIDs {
+		a.cache.Delete("account-" + accountID.String())
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/delivc/team/models"
+	"github.com/go-chi/chi/v4"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * Subject access and erasure requests of a user
+ */
+
+// UserDataExport returns every row Team holds about a user
+// [GET]/operator/users/{userId}
+func (a *API) UserDataExport(w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.FromString(chi.URLParam(r, "userId"))
+	if err != nil {
+		return badRequestError("Invalid User ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	data, err := models.ExportUserData(a.db, userID)
+	if err != nil {
+		return internalServerError("Database error exporting user data").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+
+	return sendJSON(w, http.StatusOK, data)
+}
+
+// UserDataErase removes a user from all accounts and anonymizes every other row
+// referring to it. Sole owners are replaced by the longest standing Admin member,
+// accounts without one are flagged as orphaned
+// [DELETE]/operator/users/{userId}
+func (a *API) UserDataErase(w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.FromString(chi.URLParam(r, "userId"))
+	if err != nil {
+		return badRequestError("Invalid User ID").WithErrorCode(errCodeInvalidID)
+	}
+
+	removals, err := a.removeUser(r.Context(), userID, uuid.Nil, true)
+	if err != nil {
+		return err
+	}
+
+	return sendJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":  userID,
+		"accounts": removals,
+	})
+}
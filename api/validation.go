@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+)
+
+/**
+ * Validation of request params, rules are declared in `validate` tags:
+ *
+ *   required   the field must not be empty
+ *   min=N      strings need N characters, lists N entries
+ *   max=N      strings can have N characters, lists N entries
+ *   email      the field is empty or an email address
+ *   unique     a list contains no name twice
+ */
+
+// decodeParams reads the JSON body of a request,
+// fields the params do not know are rejected
+func decodeParams(r *http.Request, params interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(params)
+}
+
+// validation collects the invalid fields of a request
+type validation struct {
+	errors []FieldError
+}
+
+func (v *validation) add(field, code, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns the collected fields as one error, nil if all are valid
+func (v *validation) err() error {
+	switch len(v.errors) {
+	case 0:
+		return nil
+	case 1:
+		e := unprocessableEntityError(v.errors[0].Message).WithErrorCode(v.errors[0].Code)
+		e.Errors = v.errors
+		return e
+	}
+	messages := []string{}
+	for _, field := range v.errors {
+		messages = append(messages, field.Message)
+	}
+	e := unprocessableEntityError(strings.Join(messages, ", ")).WithErrorCode(errCodeValidationFailed)
+	e.Errors = v.errors
+	return e
+}
+
+// validateParams checks the `validate` tags of params, partial params
+// only change the given fields so `required` is not checked
+func validateParams(params interface{}, partial bool) *validation {
+	v := &validation{}
+	v.check(reflect.Indirect(reflect.ValueOf(params)), partial)
+	return v
+}
+
+func (v *validation) check(value reflect.Value, partial bool) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			v.check(value.Field(i), partial)
+			continue
+		}
+		rules := field.Tag.Get("validate")
+		if rules == "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+		for _, rule := range strings.Split(rules, ",") {
+			if !v.apply(name, rule, value.Field(i), partial) {
+				break
+			}
+		}
+	}
+}
+
+// apply checks one rule, false stops the checks of the field
+func (v *validation) apply(name, rule string, value reflect.Value, partial bool) bool {
+	rule, arg := splitRule(rule)
+	switch rule {
+	case "required":
+		if !partial && isEmpty(value) {
+			v.add(name, errCodeRequired, "%v is required", name)
+			return false
+		}
+	case "min":
+		if length, unit := lengthOf(value); length < arg && !isEmpty(value) {
+			v.add(name, errCodeTooShort, "%v must have at least %d %v", name, arg, unit)
+			return false
+		}
+	case "max":
+		if length, unit := lengthOf(value); length > arg {
+			v.add(name, errCodeTooLong, "%v must have at most %d %v", name, arg, unit)
+			return false
+		}
+	case "email":
+		if value.String() != "" && !emailRegex.MatchString(value.String()) {
+			v.add(name, errCodeInvalidEmail, "%v is not a valid email address", name)
+			return false
+		}
+	case "unique":
+		seen := map[string]bool{}
+		for i := 0; i < value.Len(); i++ {
+			entry := value.Index(i).String()
+			if seen[entry] {
+				v.add(fmt.Sprintf("%v[%d]", name, i), errCodeDuplicate, "%v contains %v twice", name, entry)
+			}
+			seen[entry] = true
+		}
+	default:
+		panic("unknown validation rule " + rule)
+	}
+	return true
+}
+
+func splitRule(rule string) (string, int) {
+	parts := strings.SplitN(rule, "=", 2)
+	if len(parts) == 1 {
+		return parts[0], 0
+	}
+	arg, err := strconv.Atoi(parts[1])
+	if err != nil {
+		panic("invalid argument of validation rule " + rule)
+	}
+	return parts[0], arg
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	if id, ok := value.Interface().(uuid.UUID); ok {
+		return id == uuid.Nil
+	}
+	return value.IsZero()
+}
+
+// lengthOf counts the characters of strings and the entries of lists
+func lengthOf(value reflect.Value) (int, string) {
+	if value.Kind() == reflect.String {
+		return utf8.RuneCountInString(value.String()), "characters"
+	}
+	return value.Len(), "entries"
+}
+
+// validateRole checks the params of a role and that its name is unused
+// within the account and every permission is registered
+func validateRole(tx *storage.Connection, accountID, roleID uuid.UUID, params *createRoleRequest, partial bool) error {
+	v := validateParams(params, partial)
+
+	if strings.TrimSpace(params.Name) != "" {
+		exists, err := models.RoleNameExists(tx, accountID, params.Name, roleID)
+		if err != nil {
+			return internalServerError("Database error finding roles").WithErrorCode(errCodeDatabase).WithInternalError(err)
+		}
+		if exists {
+			v.add("name", errCodeNameTaken, "A role named %v exists already", params.Name)
+		}
+	}
+
+	unknown, err := models.UnknownPermissionNames(tx, append(append([]string{}, params.Permissions...), params.Deny...))
+	if err != nil {
+		return internalServerError("Database error finding permissions").WithErrorCode(errCodeDatabase).WithInternalError(err)
+	}
+	isUnknown := map[string]bool{}
+	for _, name := range unknown {
+		isUnknown[name] = true
+	}
+	for i, name := range params.Permissions {
+		if isUnknown[name] {
+			v.add(fmt.Sprintf("permissions[%d]", i), errCodeUnknownPermission, "Permission %v is not registered", name)
+		}
+	}
+	for i, name := range params.Deny {
+		if isUnknown[name] {
+			v.add(fmt.Sprintf("deny[%d]", i), errCodeUnknownPermission, "Permission %v is not registered", name)
+		}
+	}
+	return v.err()
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeParamsRejectsUnknownFields(t *testing.T) {
+	r := httptest.NewRequest("POST", "/accounts", strings.NewReader(`{"name":"Acme","nmae":"Acme"}`))
+	err := decodeParams(r, &accountCreateParams{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "nmae"`)
+
+	// members of embedded structs are known
+	r = httptest.NewRequest("PUT", "/accounts/1/roles/1", strings.NewReader(`{"name":"Editors","deny":["account-edit"]}`))
+	params := &updateRoleRequest{}
+	require.NoError(t, decodeParams(r, params))
+	assert.Equal(t, "Editors", params.Name)
+}
+
+func TestValidateParams(t *testing.T) {
+	assert.NoError(t, validateParams(&accountCreateParams{Name: "Acme"}, false).err())
+
+	err := validateParams(&accountCreateParams{Name: "  "}, false).err()
+	require.Error(t, err)
+	httpErr := err.(*HTTPError)
+	assert.Equal(t, 422, httpErr.Code)
+	assert.Equal(t, errCodeRequired, httpErr.ErrorCode)
+	assert.Equal(t, []FieldError{{Field: "name", Code: errCodeRequired, Message: "name is required"}}, httpErr.Errors)
+
+	// updates leave missing fields as they are
+	assert.NoError(t, validateParams(&accountUpdateParams{}, true).err())
+
+	err = validateParams(&accountUpdateParams{Name: strings.Repeat("ä", 256), BillingEmail: "billing"}, true).err()
+	require.Error(t, err)
+	httpErr = err.(*HTTPError)
+	assert.Equal(t, errCodeValidationFailed, httpErr.ErrorCode)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Code: errCodeTooLong, Message: "name must have at most 255 characters"},
+		{Field: "billing_email", Code: errCodeInvalidEmail, Message: "billing_email is not a valid email address"},
+	}, httpErr.Errors)
+}
+
+func TestValidateParamsUnique(t *testing.T) {
+	params := &createRoleRequest{Name: "Editors", Permissions: []string{"account-edit", "account-view", "account-edit"}}
+	err := validateParams(params, false).err()
+	require.Error(t, err)
+	assert.Equal(t, []FieldError{
+		{Field: "permissions[2]", Code: errCodeDuplicate, Message: "permissions contains account-edit twice"},
+	}, err.(*HTTPError).Errors)
+}
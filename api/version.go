@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+/**
+ * Versions of the API, each one is mounted at /{version}
+ */
+
+const apiVersion1 = "v1"
+
+// apiVersions are ordered from old to new
+var apiVersions = []string{apiVersion1}
+
+// legacyVersion is served at the unversioned paths
+const legacyVersion = apiVersion1
+
+// versioned holds the handlers of a route which changed between versions,
+// a version without own handler is served by the handler of the version before.
+// eg. `r.Get("/accounts", versioned{apiVersion1: a.AccountsGet, apiVersion2: a.AccountsGetV2}.serve)`
+type versioned map[string]apiHandler
+
+func (v versioned) handler(version string) apiHandler {
+	found := false
+	for i := len(apiVersions) - 1; i >= 0; i-- {
+		if apiVersions[i] == version {
+			found = true
+		}
+		if h, ok := v[apiVersions[i]]; found && ok {
+			return h
+		}
+	}
+	return nil
+}
+
+func (v versioned) serve(w http.ResponseWriter, r *http.Request) error {
+	version := getAPIVersion(r.Context())
+	h := v.handler(version)
+	if h == nil {
+		return notFoundError("Not available in API version %v", version).WithErrorCode(errCodeNotInVersion)
+	}
+	return h(w, r)
+}
+
+// deprecateLegacyPaths serves the unversioned paths as legacy version,
+// responses tell clients to move to the versioned path before the sunset
+func (a *API) deprecateLegacyPaths(w http.ResponseWriter, r *http.Request) (context.Context, error) {
+	w.Header().Set("Deprecation", "true")
+	if sunset := a.config.API.LegacySunset; !sunset.IsZero() {
+		w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Add("Link", "</"+legacyVersion+r.URL.Path+">; rel=\"successor-version\"")
+	return withAPIVersion(legacyVersion)(w, r)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/delivc/team/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyPathsAreDeprecated(t *testing.T) {
+	config := &conf.GlobalConfiguration{}
+	config.API.LegacySunset = time.Date(2021, 6, 30, 0, 0, 0, 0, time.UTC)
+	api := New(context.Background(), config, nil, "test")
+
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Wed, 30 Jun 2021 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/openapi.json>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	api.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
+
+func TestVersionedHandler(t *testing.T) {
+	defer func(versions []string) { apiVersions = versions }(apiVersions)
+	apiVersions = []string{"v1", "v2", "v3"}
+
+	served := ""
+	handlerOf := func(name string) apiHandler {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			served = name
+			return nil
+		}
+	}
+	v := versioned{"v2": handlerOf("v2")}
+
+	serve := func(version string) error {
+		served = ""
+		r := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+		r = r.WithContext(context.WithValue(r.Context(), apiVersionKey, version))
+		return v.serve(httptest.NewRecorder(), r)
+	}
+
+	require.NoError(t, serve("v2"))
+	assert.Equal(t, "v2", served)
+	// newer versions fall back to the handler before
+	require.NoError(t, serve("v3"))
+	assert.Equal(t, "v2", served)
+	// the route did not exist in v1
+	err := serve("v1")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, err.(*HTTPError).Code)
+}
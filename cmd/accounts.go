@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/delivc/team/conf"
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var exportFile = ""
+var importPolicy = models.ImportFail
+
+var accountsCmd = cobra.Command{
+	Use:  "accounts",
+	Long: "Export and import accounts",
+}
+
+var accountsExportCmd = cobra.Command{
+	Use:  "export [id]",
+	Long: "Export an account as JSON, to stdout or the file given with --output",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfig(cmd, func(globalConfig *conf.GlobalConfiguration, config *conf.Configuration) {
+			exportAccount(globalConfig, args[0])
+		})
+	},
+}
+
+var accountsImportCmd = cobra.Command{
+	Use:  "import [file]",
+	Long: "Import an exported account, --on-conflict decides what happens if it exists already: fail, replace or copy",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfig(cmd, func(globalConfig *conf.GlobalConfiguration, config *conf.Configuration) {
+			importAccount(globalConfig, args[0])
+		})
+	},
+}
+
+func init() {
+	accountsExportCmd.Flags().StringVarP(&exportFile, "output", "o", "", "the file to write the export to")
+	accountsImportCmd.Flags().StringVar(&importPolicy, "on-conflict", models.ImportFail, "fail, replace or copy an existing account")
+	accountsCmd.AddCommand(&accountsExportCmd, &accountsImportCmd)
+}
+
+func exportAccount(globalConfig *conf.GlobalConfiguration, id string) {
+	accountID, err := uuid.FromString(id)
+	if err != nil {
+		logrus.Fatalf("Invalid account ID %v", id)
+	}
+
+	db, err := storage.Dial(globalConfig)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	export, err := models.ExportAccount(db, accountID)
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "exporting account"))
+	}
+
+	var out io.Writer = os.Stdout
+	if exportFile != "" {
+		f, err := os.Create(exportFile)
+		if err != nil {
+			logrus.Fatalf("%+v", errors.Wrap(err, "creating export file"))
+		}
+		defer f.Close()
+		out = f
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "writing export"))
+	}
+}
+
+func importAccount(globalConfig *conf.GlobalConfiguration, filename string) {
+	switch importPolicy {
+	case models.ImportFail, models.ImportReplace, models.ImportCopy:
+	default:
+		logrus.Fatalf("Unknown --on-conflict %v", importPolicy)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "opening export file"))
+	}
+	defer f.Close()
+
+	export := &models.AccountExport{}
+	if err := json.NewDecoder(f).Decode(export); err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "reading export file"))
+	}
+
+	db, err := storage.Dial(globalConfig)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	var result *models.ImportResult
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		result, terr = models.ImportAccount(tx, uuid.Nil, export, importPolicy)
+		return terr
+	})
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "importing account"))
+	}
+	logrus.Infof("Imported account %s with %d roles and %d members", result.AccountID, len(result.RoleIDs), len(export.Members))
+}
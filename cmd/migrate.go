@@ -1,11 +1,12 @@
 package cmd
 
 import (
-	"database/sql"
 	"net/url"
 
 	"github.com/delivc/team/conf"
 	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
 	"github.com/gobuffalo/pop/v5"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -44,6 +45,7 @@ func migrate(cmd *cobra.Command, args []string) {
 		deets.Options = map[string]string{
 			"Namespace": globalConfig.DB.Namespace + "_",
 		}
+		namespace.SetNamespace(globalConfig.DB.Namespace)
 	}
 
 	db, err := pop.NewConnection(deets)
@@ -87,52 +89,11 @@ func migrate(cmd *cobra.Command, args []string) {
 		logrus.Fatalf("%+v", errors.Wrap(err, "seeding status"))
 	}
 
-	permissions := []string{
-		"spaces-create",
-		"spaces-edit",
-		"spaces-delete",
-		"spaces-read-apikeys",
-		"spaces-create-apikeys",
-		"spaces-destroy-apikeys",
-		"spaces-create-models",
-		"spaces-edit-models",
-		"spaces-destroy-models",
-		"spaces-create-content",
-		"spaces-edit-content",
-		"spaces-destroy-content",
-		"spaces-create-assets",
-		"spaces-destroy-assets",
-		"account-edit",
-		"account-destroy",
-		"account-users-invite",
-		"account-users-remove",
-		"account-role-create",
-		"account-role-update",
-		"account-role-destroy",
-	}
-
 	err = db.Transaction(func(tx *pop.Connection) error {
-		for _, permission := range permissions {
-			p, err := models.NewPermission(permission)
-			if err != nil {
-				logrus.Fatalf("%+v", errors.Wrap(err, "creating permission"))
-				return err
-			}
-			obj := &models.Permission{}
-			if err := tx.Q().Where("name = ?", p.Name).First(obj); err != nil {
-				if errors.Cause(err) != sql.ErrNoRows {
-					return err
-				}
-			}
-			if obj.Name == "" {
-				tx.Create(p)
-			}
-		}
-
-		return nil
+		_, err := models.SeedPermissions(&storage.Connection{Connection: tx}, models.DefaultPermissions)
+		return err
 	})
-
-	// Migrate default Permissions
-	// can:
-	// manage billing
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "seeding permissions"))
+	}
 }
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/delivc/team/conf"
+	"github.com/delivc/team/models"
+	"github.com/delivc/team/storage"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var permissionsCmd = cobra.Command{
+	Use:  "permissions",
+	Long: "Manage the permission registry",
+}
+
+var permissionsRegisterCmd = cobra.Command{
+	Use:  "register [file]",
+	Long: "Register the permissions of a service from a JSON file. Registering is idempotent.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfig(cmd, func(globalConfig *conf.GlobalConfiguration, config *conf.Configuration) {
+			registerPermissions(globalConfig, args[0])
+		})
+	},
+}
+
+var permissionsDeprecateCmd = cobra.Command{
+	Use:  "deprecate [name]",
+	Long: "Mark a permission as deprecated",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfig(cmd, func(globalConfig *conf.GlobalConfiguration, config *conf.Configuration) {
+			changePermission(globalConfig, args[0], (*models.Permission).Deprecate)
+		})
+	},
+}
+
+var permissionsRetireCmd = cobra.Command{
+	Use:  "retire [name]",
+	Long: "Retire a permission and detach it from all roles",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		execWithConfig(cmd, func(globalConfig *conf.GlobalConfiguration, config *conf.Configuration) {
+			changePermission(globalConfig, args[0], (*models.Permission).Retire)
+		})
+	},
+}
+
+func init() {
+	permissionsCmd.AddCommand(&permissionsRegisterCmd, &permissionsDeprecateCmd, &permissionsRetireCmd)
+}
+
+func registerPermissions(globalConfig *conf.GlobalConfiguration, filename string) {
+	f, err := os.Open(filename)
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "opening permissions file"))
+	}
+	defer f.Close()
+
+	definitions := []models.PermissionDefinition{}
+	if err := json.NewDecoder(f).Decode(&definitions); err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "reading permissions file"))
+	}
+
+	db, err := storage.Dial(globalConfig)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	var permissions []models.Permission
+	err = db.Transaction(func(tx *storage.Connection) error {
+		var terr error
+		permissions, terr = models.RegisterPermissions(tx, definitions)
+		return terr
+	})
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "registering permissions"))
+	}
+	logrus.Infof("Registered %d permissions", len(permissions))
+}
+
+func changePermission(globalConfig *conf.GlobalConfiguration, name string, fn func(*models.Permission, *storage.Connection) error) {
+	db, err := storage.Dial(globalConfig)
+	if err != nil {
+		logrus.Fatalf("Error opening database: %+v", err)
+	}
+	defer db.Close()
+
+	err = db.Transaction(func(tx *storage.Connection) error {
+		permission, terr := models.FindPermissionByName(tx, name)
+		if terr != nil {
+			return terr
+		}
+		return fn(permission, tx)
+	})
+	if err != nil {
+		logrus.Fatalf("%+v", errors.Wrap(err, "updating permission "+name))
+	}
+	logrus.Infof("Updated permission %s", name)
+}
@@ -18,7 +18,7 @@ var rootCmd = cobra.Command{
 // RootCommand will setup and return the root command
 func RootCommand() *cobra.Command {
 	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "the config file to use")
-	rootCmd.AddCommand(&serveCmd, &migrateCmd, &versionCmd)
+	rootCmd.AddCommand(&serveCmd, &migrateCmd, &permissionsCmd, &accountsCmd, &versionCmd)
 
 	return &rootCmd
 }
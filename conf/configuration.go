@@ -41,12 +41,18 @@ type GlobalConfiguration struct {
 		Port            int `envconfig:"PORT" default:"8083"`
 		Endpoint        string
 		RequestIDHeader string `envconfig:"REQUEST_ID_HEADER"`
+		// LegacySunset is announced in the `Sunset` header of the unversioned paths
+		LegacySunset time.Time `split_words:"true"`
 	}
-	IdentityEndpoint string        `envconfig:"DELIVC_IDENTITY_ENDPOINT" required:"true"`
-	Logging          loggingConfig `envconfig:"LOG"`
-	OperatorToken    string        `split_words:"true" required:"true"`
-	DB               DBConfiguration
-	SMTP             SMTPConfiguration
+	IdentityEndpoint      string        `envconfig:"DELIVC_IDENTITY_ENDPOINT" required:"true"`
+	IdentityWebhookSecret string        `envconfig:"DELIVC_IDENTITY_WEBHOOK_SECRET"`
+	Logging               loggingConfig `envconfig:"LOG"`
+	OperatorToken         string        `split_words:"true" required:"true"`
+	DB                    DBConfiguration
+	SMTP                  SMTPConfiguration
+
+	RoleTemplatesFile string                     `split_words:"true"`
+	RoleTemplates     RoleTemplatesConfiguration `ignored:"true"`
 }
 
 func loadEnvironment(filename string) error {
@@ -81,13 +87,21 @@ func LoadGlobal(filename string) (*GlobalConfiguration, error) {
 	if config.SMTP.MaxFrequency == 0 {
 		config.SMTP.MaxFrequency = 15 * time.Minute
 	}
+
+	templates, err := LoadRoleTemplates(config.RoleTemplatesFile)
+	if err != nil {
+		return nil, err
+	}
+	config.RoleTemplates = templates
 	return config, nil
 }
 
 // EmailContentConfiguration holds the configuration for emails, both subjects and template URLs.
 type EmailContentConfiguration struct {
-	Invite       string `json:"invite"`
-	Confirmation string `json:"confirmation"`
+	Invite                string `json:"invite"`
+	Confirmation          string `json:"confirmation"`
+	AccessRequestApproved string `json:"access_request_approved" split_words:"true"`
+	AccessRequestDenied   string `json:"access_request_denied" split_words:"true"`
 }
 
 // Configuration holds all the per-instance configuration.
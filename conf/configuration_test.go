@@ -15,11 +15,37 @@ func TestMain(m *testing.M) {
 func TestGlobal(t *testing.T) {
 	os.Setenv("DELIVC_DB_DRIVER", "pgsql")
 	os.Setenv("DELIVC_DATABASE_URL", "fake")
+	os.Setenv("DELIVC_IDENTITY_ENDPOINT", "http://localhost:8081")
 	os.Setenv("DELIVC_OPERATOR_TOKEN", "token")
 	os.Setenv("DELIVC_API_REQUEST_ID_HEADER", "X-Request-ID")
 	gc, err := LoadGlobal("")
 	require.NoError(t, err)
 	require.NotNil(t, gc)
+	require.Equal(t, DefaultRoleTemplates, gc.RoleTemplates)
+}
+
+func TestRoleTemplatesForAudience(t *testing.T) {
+	templates := RoleTemplatesConfiguration{
+		DefaultAudience:   {{Name: "Admin", Permissions: []string{"*"}, Owner: true}},
+		"shop.delivc.com": {{Name: "Manager", Permissions: []string{"account-edit"}, Owner: true}},
+	}
+	require.Equal(t, "Manager", templates.ForAudience("shop.delivc.com")[0].Name)
+	require.Equal(t, "Admin", templates.ForAudience("app.delivc.com")[0].Name)
+}
+
+func TestRoleTemplatesValidate(t *testing.T) {
+	require.NoError(t, DefaultRoleTemplates.Validate())
+
+	// accounts of unknown audiences could not be created
+	templates := RoleTemplatesConfiguration{
+		"shop.delivc.com": {{Name: "Manager", Permissions: []string{"account-edit"}, Owner: true}},
+	}
+	require.EqualError(t, templates.Validate(), `role templates for the default audience "*" are missing`)
+
+	templates = RoleTemplatesConfiguration{
+		DefaultAudience: {{Name: "Editor", Permissions: []string{"spaces-edit-content"}}},
+	}
+	require.EqualError(t, templates.Validate(), `role templates of audience "*" have no owner template`)
 }
 
 func TestInstance(t *testing.T) {
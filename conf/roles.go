@@ -0,0 +1,90 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DefaultAudience is used for audiences without their own role templates
+const DefaultAudience = "*"
+
+// RoleTemplate describes a role which is created for every new account
+type RoleTemplate struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	// Owner marks the role the creator of an account gets
+	Owner bool `json:"owner"`
+}
+
+// RoleTemplatesConfiguration maps audiences to their role templates
+type RoleTemplatesConfiguration map[string][]RoleTemplate
+
+// DefaultRoleTemplates are used if no role templates file is configured
+var DefaultRoleTemplates = RoleTemplatesConfiguration{
+	DefaultAudience: {
+		{Name: "Admin", Permissions: []string{"*"}, Owner: true},
+		{Name: "Editor", Permissions: []string{
+			"spaces-create-models",
+			"spaces-edit-models",
+			"spaces-destroy-models",
+			"spaces-create-content",
+			"spaces-edit-content",
+			"spaces-destroy-content",
+			"spaces-create-assets",
+			"spaces-destroy-assets",
+		}},
+		{Name: "Viewer", Permissions: []string{}},
+		{Name: "Billing", Permissions: []string{"account-edit"}},
+	},
+}
+
+// ForAudience returns the role templates of an audience,
+// or the ones of the default audience
+func (c RoleTemplatesConfiguration) ForAudience(aud string) []RoleTemplate {
+	if templates, ok := c[aud]; ok {
+		return templates
+	}
+	return c[DefaultAudience]
+}
+
+// Validate checks that the default audience has templates
+// and every audience has a template for the owner of an account
+func (c RoleTemplatesConfiguration) Validate() error {
+	if len(c[DefaultAudience]) == 0 {
+		return fmt.Errorf("role templates for the default audience %q are missing", DefaultAudience)
+	}
+	for aud, templates := range c {
+		owner := false
+		for _, template := range templates {
+			owner = owner || template.Owner
+		}
+		if !owner {
+			return fmt.Errorf("role templates of audience %q have no owner template", aud)
+		}
+	}
+	return nil
+}
+
+// LoadRoleTemplates reads role templates from a JSON file and validates them,
+// an empty filename returns the default templates
+func LoadRoleTemplates(filename string) (RoleTemplatesConfiguration, error) {
+	if filename == "" {
+		return DefaultRoleTemplates, nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	templates := RoleTemplatesConfiguration{}
+	if err := json.NewDecoder(f).Decode(&templates); err != nil {
+		return nil, err
+	}
+	if err := templates.Validate(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
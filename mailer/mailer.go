@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"github.com/delivc/team/conf"
+	"github.com/delivc/team/models"
+)
+
+// Mailer sends the notification mails of team
+type Mailer interface {
+	Send(to, subject, body string) error
+	AccessRequestApprovedMail(request *models.AccessRequest, account *models.Account) error
+	AccessRequestDeniedMail(request *models.AccessRequest, account *models.Account) error
+}
+
+// NewMailer returns a new mailer for the instance configuration,
+// without a SMTP host mails are dropped silently
+func NewMailer(config *conf.Configuration) Mailer {
+	if config == nil || config.SMTP.Host == "" {
+		return &noopMailer{}
+	}
+
+	return &TemplateMailer{
+		Config: config,
+		Mailer: &SMTPMailer{
+			Host: config.SMTP.Host,
+			Port: config.SMTP.Port,
+			User: config.SMTP.User,
+			Pass: config.SMTP.Pass,
+			From: config.SMTP.AdminEmail,
+		},
+	}
+}
+
+func withDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,18 @@
+package mailer
+
+import "github.com/delivc/team/models"
+
+type noopMailer struct {
+}
+
+func (m *noopMailer) Send(to, subject, body string) error {
+	return nil
+}
+
+func (m *noopMailer) AccessRequestApprovedMail(request *models.AccessRequest, account *models.Account) error {
+	return nil
+}
+
+func (m *noopMailer) AccessRequestDeniedMail(request *models.AccessRequest, account *models.Account) error {
+	return nil
+}
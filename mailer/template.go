@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/delivc/team/conf"
+	"github.com/delivc/team/models"
+)
+
+// TemplateMailer renders the notification mails and sends them
+type TemplateMailer struct {
+	Config *conf.Configuration
+	Mailer *SMTPMailer
+}
+
+const defaultAccessRequestApprovedMail = `<h2>Your request has been approved</h2>
+<p>You are now a member of {{ .AccountName }}.</p>
+<p><a href="{{ .SiteURL }}">Go to {{ .AccountName }}</a></p>`
+
+const defaultAccessRequestDeniedMail = `<h2>Your request has been denied</h2>
+<p>Your request to join {{ .AccountName }} has been denied.</p>`
+
+// Send sends a mail with the given html body
+func (m *TemplateMailer) Send(to, subject, body string) error {
+	return m.Mailer.Mail(to, subject, body)
+}
+
+// AccessRequestApprovedMail tells the requester that they are now a member
+func (m *TemplateMailer) AccessRequestApprovedMail(request *models.AccessRequest, account *models.Account) error {
+	return m.mail(
+		request.Email,
+		withDefault(m.Config.Mailer.Subjects.AccessRequestApproved, "Your request has been approved"),
+		defaultAccessRequestApprovedMail,
+		account,
+	)
+}
+
+// AccessRequestDeniedMail tells the requester that they were not let in
+func (m *TemplateMailer) AccessRequestDeniedMail(request *models.AccessRequest, account *models.Account) error {
+	return m.mail(
+		request.Email,
+		withDefault(m.Config.Mailer.Subjects.AccessRequestDenied, "Your request has been denied"),
+		defaultAccessRequestDeniedMail,
+		account,
+	)
+}
+
+func (m *TemplateMailer) mail(to, subject, body string, account *models.Account) error {
+	tmpl, err := template.New("mail").Parse(body)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, map[string]interface{}{
+		"SiteURL":     m.Config.SiteURL,
+		"AccountName": account.Name,
+	}); err != nil {
+		return err
+	}
+	return m.Send(to, subject, buf.String())
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `{{ index .Options "Namespace" }}role_assignments` (
+  `id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `user_id` varchar(255) NOT NULL,
+  `role_id` varchar(255) NOT NULL,
+  `resource_type` varchar(255) NOT NULL,
+  `resource_id` varchar(255) NOT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `role_assignments_scope` (`account_id`, `user_id`, `role_id`, `resource_type`, `resource_id`),
+  FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,7 @@
+ALTER TABLE `{{ index .Options "Namespace" }}permissions`
+  DROP INDEX `permissions_name`,
+  DROP COLUMN `retired_at`,
+  DROP COLUMN `deprecated_at`,
+  DROP COLUMN `category`,
+  DROP COLUMN `namespace`,
+  DROP COLUMN `description`;
@@ -0,0 +1,7 @@
+ALTER TABLE `{{ index .Options "Namespace" }}permissions`
+  ADD COLUMN `description` varchar(255) DEFAULT NULL AFTER `name`,
+  ADD COLUMN `namespace` varchar(255) DEFAULT NULL AFTER `description`,
+  ADD COLUMN `category` varchar(255) DEFAULT NULL AFTER `namespace`,
+  ADD COLUMN `deprecated_at` timestamp NULL DEFAULT NULL AFTER `category`,
+  ADD COLUMN `retired_at` timestamp NULL DEFAULT NULL AFTER `deprecated_at`,
+  ADD UNIQUE KEY `permissions_name` (`name`);
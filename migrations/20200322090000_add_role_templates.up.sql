@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}roles`
+  ADD COLUMN `template` varchar(255) DEFAULT NULL AFTER `name`;
@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}roles`
+  DROP COLUMN `is_system`;
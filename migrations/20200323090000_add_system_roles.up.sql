@@ -0,0 +1,5 @@
+ALTER TABLE `{{ index .Options "Namespace" }}roles`
+  ADD COLUMN `is_system` tinyint(1) NOT NULL DEFAULT 0 AFTER `template`;
+
+UPDATE `{{ index .Options "Namespace" }}roles` SET `template` = `name` WHERE `name` = 'Admin' AND `template` IS NULL;
+UPDATE `{{ index .Options "Namespace" }}roles` SET `is_system` = 1 WHERE `template` IS NOT NULL;
@@ -0,0 +1,3 @@
+DELETE FROM `{{ index .Options "Namespace" }}roles_permissions` WHERE `effect` = 'deny';
+ALTER TABLE `{{ index .Options "Namespace" }}roles_permissions`
+  DROP COLUMN `effect`;
@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}roles_permissions`
+  ADD COLUMN `effect` varchar(16) NOT NULL DEFAULT 'allow' AFTER `permission_id`;
@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}roles_permissions`
+  DROP COLUMN `raw_conditions`;
@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}roles_permissions`
+  ADD COLUMN `raw_conditions` JSON NULL DEFAULT NULL AFTER `effect`;
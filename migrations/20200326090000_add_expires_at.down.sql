@@ -0,0 +1,5 @@
+ALTER TABLE `{{ index .Options "Namespace" }}role_assignments`
+  DROP COLUMN `expires_at`;
+
+ALTER TABLE `{{ index .Options "Namespace" }}accounts_users`
+  DROP COLUMN `expires_at`;
@@ -0,0 +1,5 @@
+ALTER TABLE `{{ index .Options "Namespace" }}accounts_users`
+  ADD COLUMN `expires_at` timestamp NULL DEFAULT NULL AFTER `invited_by`;
+
+ALTER TABLE `{{ index .Options "Namespace" }}role_assignments`
+  ADD COLUMN `expires_at` timestamp NULL DEFAULT NULL AFTER `resource_id`;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `{{ index .Options "Namespace" }}audit_log_entries` (
+  `instance_id` varchar(255) DEFAULT NULL,
+  `id` varchar(255) NOT NULL,
+  `account_id` varchar(255) DEFAULT NULL,
+  `actor_id` varchar(255) DEFAULT NULL,
+  `subject_id` varchar(255) DEFAULT NULL,
+  `action` varchar(255) NOT NULL,
+  `payload` JSON NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `audit_log_entries_account_id` (`account_id`),
+  KEY `audit_log_entries_subject_id` (`subject_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
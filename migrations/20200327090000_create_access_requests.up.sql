@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `{{ index .Options "Namespace" }}access_requests` (
+  `id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `user_id` varchar(255) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `message` text NULL DEFAULT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `role_id` varchar(255) NULL DEFAULT NULL,
+  `decided_by` varchar(255) NULL DEFAULT NULL,
+  `decided_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  KEY `access_requests_account_id_status` (`account_id`, `status`),
+  KEY `access_requests_user_id` (`user_id`),
+  FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `{{ index .Options "Namespace" }}account_domains` (
+  `id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `role_id` varchar(255) NOT NULL,
+  `domain` varchar(255) NOT NULL,
+  `verification_token` varchar(255) NOT NULL,
+  `verified_at` timestamp NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `account_domains_account_id_domain` (`account_id`, `domain`),
+  KEY `account_domains_domain` (`domain`),
+  FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
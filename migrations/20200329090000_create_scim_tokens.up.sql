@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `{{ index .Options "Namespace" }}scim_tokens` (
+  `id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `role_id` varchar(255) NOT NULL,
+  `token_hash` varchar(255) NOT NULL,
+  `created_by` varchar(255) NULL DEFAULT NULL,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `scim_tokens_account_id` (`account_id`),
+  UNIQUE KEY `scim_tokens_token_hash` (`token_hash`),
+  FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
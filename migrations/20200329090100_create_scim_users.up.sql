@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `{{ index .Options "Namespace" }}scim_users` (
+  `id` varchar(255) NOT NULL,
+  `account_id` varchar(255) NOT NULL,
+  `user_id` varchar(255) NULL DEFAULT NULL,
+  `role_id` varchar(255) NOT NULL,
+  `external_id` varchar(255) NULL DEFAULT NULL,
+  `user_name` varchar(255) NOT NULL,
+  `display_name` varchar(255) NULL DEFAULT NULL,
+  `active` tinyint(1) NOT NULL DEFAULT 1,
+  `created_at` timestamp NULL DEFAULT NULL,
+  `updated_at` timestamp NULL DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `scim_users_account_id_user_name` (`account_id`, `user_name`),
+  KEY `scim_users_user_name` (`user_name`),
+  FOREIGN KEY (account_id) REFERENCES accounts (id) ON DELETE CASCADE ON UPDATE CASCADE,
+  FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE RESTRICT ON UPDATE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}accounts`
+  DROP COLUMN `orphaned_at`;
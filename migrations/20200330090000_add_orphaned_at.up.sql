@@ -0,0 +1,2 @@
+ALTER TABLE `{{ index .Options "Namespace" }}accounts`
+  ADD COLUMN `orphaned_at` timestamp NULL DEFAULT NULL AFTER `raw_account_meta_data`;
@@ -0,0 +1,4 @@
+DELETE FROM `{{ index .Options "Namespace" }}roles_permissions`
+  WHERE `permission_id` IN (SELECT `id` FROM `{{ index .Options "Namespace" }}permissions` WHERE `name` = '*');
+
+DELETE FROM `{{ index .Options "Namespace" }}permissions` WHERE `name` = '*';
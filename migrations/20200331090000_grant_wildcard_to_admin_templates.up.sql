@@ -0,0 +1,9 @@
+INSERT INTO `{{ index .Options "Namespace" }}permissions` (`id`, `name`, `description`, `namespace`, `category`, `created_at`, `updated_at`)
+  SELECT UUID(), '*', 'Every permission, including the ones registered in the future', 'team', 'wildcards', NOW(), NOW() FROM DUAL
+  WHERE NOT EXISTS (SELECT 1 FROM `{{ index .Options "Namespace" }}permissions` WHERE `name` = '*');
+
+INSERT INTO `{{ index .Options "Namespace" }}roles_permissions` (`id`, `role_id`, `permission_id`, `effect`, `created_at`, `updated_at`)
+  SELECT UUID(), r.`id`, p.`id`, 'allow', NOW(), NOW()
+  FROM `{{ index .Options "Namespace" }}roles` r JOIN `{{ index .Options "Namespace" }}permissions` p ON p.`name` = '*'
+  WHERE r.`template` = 'Admin'
+  AND NOT EXISTS (SELECT 1 FROM `{{ index .Options "Namespace" }}roles_permissions` rp WHERE rp.`role_id` = r.`id` AND rp.`permission_id` = p.`id`);
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Status of an access request
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+// AccessRequest is the request of a user to join an account
+type AccessRequest struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	AccountID uuid.UUID  `json:"account_id" db:"account_id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	Email     string     `json:"email" db:"email"`
+	Message   string     `json:"message,omitempty" db:"message"`
+	Status    string     `json:"status" db:"status"`
+	RoleID    *uuid.UUID `json:"role_id,omitempty" db:"role_id"`
+	DecidedBy *uuid.UUID `json:"decided_by,omitempty" db:"decided_by"`
+	DecidedAt *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TableName returns the given tablename of the model
+func (AccessRequest) TableName() string {
+	tableName := "access_requests"
+
+	if namespace.GetNamespace() != "" {
+		return namespace.GetNamespace() + "_" + tableName
+	}
+
+	return tableName
+}
+
+// NewAccessRequest creates a new pending AccessRequest
+// does not create!!! the request in the database
+func NewAccessRequest(accountID, userID uuid.UUID, email, message string) (*AccessRequest, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error generating unique id")
+	}
+
+	request := &AccessRequest{
+		ID:        id,
+		AccountID: accountID,
+		UserID:    userID,
+		Email:     email,
+		Message:   message,
+		Status:    AccessRequestPending,
+	}
+	return request, nil
+}
+
+// IsPending checks if the request is still waiting for a decision
+func (ar *AccessRequest) IsPending() bool {
+	return ar.Status == AccessRequestPending
+}
+
+// Approve lets the requester in with the given role,
+// the caller has to clear memberships which have run out with ClearExpiredAccountUser
+func (ar *AccessRequest) Approve(tx *storage.Connection, roleID, approverID uuid.UUID) error {
+	now := time.Now()
+	member := &AccountUser{
+		AccountID:   ar.AccountID,
+		UserID:      ar.UserID,
+		RoleID:      roleID,
+		InvitedAt:   &ar.CreatedAt,
+		InvitedBy:   approverID,
+		ConfirmedAt: &now,
+	}
+	if err := tx.Create(member); err != nil {
+		return errors.Wrap(err, "Error attaching user to account")
+	}
+
+	ar.RoleID = &roleID
+	return ar.decide(tx, AccessRequestApproved, approverID, now)
+}
+
+// Deny rejects the request
+func (ar *AccessRequest) Deny(tx *storage.Connection, approverID uuid.UUID) error {
+	return ar.decide(tx, AccessRequestDenied, approverID, time.Now())
+}
+
+func (ar *AccessRequest) decide(tx *storage.Connection, status string, approverID uuid.UUID, now time.Time) error {
+	ar.Status = status
+	ar.DecidedBy = &approverID
+	ar.DecidedAt = &now
+	return tx.UpdateOnly(ar, "status", "role_id", "decided_by", "decided_at", "updated_at")
+}
+
+// FindAccessRequests returns the requests of an account,
+// an empty status returns all of them
+func FindAccessRequests(tx *storage.Connection, accountID uuid.UUID, status string) ([]*AccessRequest, error) {
+	obj := []*AccessRequest{}
+	q := tx.Q().Where("account_id = ?", accountID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if err := q.Order("created_at ASC").All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding access requests")
+	}
+	return obj, nil
+}
+
+// FindAccessRequestByAccountAndID returns a single request of an account
+func FindAccessRequestByAccountAndID(tx *storage.Connection, accountID, id uuid.UUID) (*AccessRequest, error) {
+	obj := &AccessRequest{}
+	if err := tx.Q().Where("account_id = ? and id = ?", accountID, id).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccessRequestNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding access request")
+	}
+	return obj, nil
+}
+
+// FindPendingAccessRequest returns the open request of a user for an account
+func FindPendingAccessRequest(tx *storage.Connection, accountID, userID uuid.UUID) (*AccessRequest, error) {
+	obj := &AccessRequest{}
+	if err := tx.Q().Where("account_id = ? and user_id = ? and status = ?", accountID, userID, AccessRequestPending).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccessRequestNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding access request")
+	}
+	return obj, nil
+}
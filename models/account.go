@@ -2,6 +2,10 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/delivc/team/storage"
@@ -26,6 +30,8 @@ type Account struct {
 	OwnerIDs        JSONMap `json:"owner_ids" db:"raw_owner_ids"`
 
 	AccountMetaData JSONMap `json:"account_metadata,omitempty" db:"raw_account_meta_data"`
+	// OrphanedAt is set once the last owner is gone and nobody could take over
+	OrphanedAt *time.Time `json:"orphaned_at,omitempty" db:"orphaned_at"`
 
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
@@ -54,19 +60,62 @@ func (a *Account) BeforeSave(tx *pop.Connection) error {
 
 // IsOwner checks if given uid is owner of account
 func (a *Account) IsOwner(userID uuid.UUID) bool {
-	for _, value := range a.OwnerIDs {
-		if value == userID.String() {
+	for _, owner := range a.Owners() {
+		if owner == userID {
 			return true
 		}
 	}
 	return false
+}
 
+// Owners returns the IDs of all owners in the order they were added
+func (a *Account) Owners() []uuid.UUID {
+	keys := make([]string, 0, len(a.OwnerIDs))
+	for key := range a.OwnerIDs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	owners := []uuid.UUID{}
+	for _, key := range keys {
+		var id uuid.UUID
+		switch v := a.OwnerIDs[key].(type) {
+		case uuid.UUID:
+			id = v
+		case string:
+			id = uuid.FromStringOrNil(v)
+		}
+		if id != uuid.Nil {
+			owners = append(owners, id)
+		}
+	}
+	return owners
+}
+
+// IsLastOwner checks if the user is the only owner of the account
+func (a *Account) IsLastOwner(userID uuid.UUID) bool {
+	owners := a.Owners()
+	return len(owners) == 1 && owners[0] == userID
 }
 
-// IsMember iterates over AccountUser
+// SetOwners replaces the owners of the account
+func (a *Account) SetOwners(owners []uuid.UUID) {
+	a.OwnerIDs = JSONMap{}
+	for i, id := range owners {
+		a.OwnerIDs[strconv.Itoa(i)] = id.String()
+	}
+}
+
+// IsMember iterates over AccountUser,
+// memberships which have run out are ignored
 func (a *Account) IsMember(userID uuid.UUID) bool {
-	for _, value := range a.AccountUser {
-		if value.ID == userID {
+	for _, value := range activeAccountUsers(a.AccountUser, time.Now()) {
+		if value.UserID == userID {
 			return true
 		}
 	}
@@ -74,29 +123,15 @@ func (a *Account) IsMember(userID uuid.UUID) bool {
 	return false
 }
 
-// HasPermissionTo checks if given user is inside a role with request permission
-func (a *Account) HasPermissionTo(tx *storage.Connection, permission string, userID uuid.UUID) bool {
-	// get related roles with permissions
-	roles, err := FindRolesByAccount(tx, a.ID)
-	if err != nil {
-		return false
-	}
-
-	for _, user := range a.AccountUser {
-		if user.UserID == userID {
-			for _, role := range roles {
-				if user.RoleID == role.ID {
-					for _, rperm := range role.Permissions {
-						if rperm.Name == permission {
-							return true
-						}
-					}
-				}
-
-			}
+// Membership returns the active membership of the user, nil if it is no member
+func (a *Account) Membership(userID uuid.UUID) *AccountUser {
+	for _, value := range activeAccountUsers(a.AccountUser, time.Now()) {
+		if value.UserID == userID {
+			member := value
+			return &member
 		}
 	}
-	return false
+	return nil
 }
 
 // UpdateName updates the name of the account
@@ -176,6 +211,15 @@ func DeleteAccount(tx *storage.Connection, accountID uuid.UUID) (bool, error) {
 	return true, nil
 }
 
+// LockAccount locks the row of the account until the transaction ends,
+// concurrent changes of its owners wait for each other
+func LockAccount(tx *storage.Connection, accountID uuid.UUID) error {
+	if err := tx.RawQuery("SELECT id FROM "+Account{}.TableName()+" WHERE id = ? FOR UPDATE", accountID).Exec(); err != nil {
+		return errors.Wrap(err, "error locking account")
+	}
+	return nil
+}
+
 func findAccount(tx *storage.Connection, query string, args ...interface{}) (*Account, error) {
 	obj := &Account{}
 	if err := tx.Q().Eager().Where(query, args...).First(obj); err != nil {
@@ -184,6 +228,7 @@ func findAccount(tx *storage.Connection, query string, args ...interface{}) (*Ac
 		}
 		return nil, errors.Wrap(err, "error finding account")
 	}
+	obj.AccountUser = activeAccountUsers(obj.AccountUser, time.Now())
 	return obj, nil
 }
 
@@ -192,37 +237,138 @@ func FindAccountByID(tx *storage.Connection, id uuid.UUID) (*Account, error) {
 	return findAccount(tx, "id = ?", id)
 }
 
-// FindAccounts searches for Accounts in the given "Audience"
-func FindAccounts(tx *storage.Connection, userID uuid.UUID, pageParams *Pagination, sortParams *SortParams) ([]*Account, error) {
+// Associations of an account which are loaded on demand
+const (
+	AccountRoles           = "roles"
+	AccountUsers           = "users"
+	AccountRolePermissions = "roles.permissions"
+)
+
+// eagerAccounts loads only the requested associations,
+// pop loads all of them if Eager is called without any
+func eagerAccounts(q *pop.Query, includes []string) *pop.Query {
+	associations := []string{}
+	for _, include := range includes {
+		switch include {
+		case AccountRoles:
+			associations = append(associations, "Roles")
+		case AccountUsers:
+			associations = append(associations, "AccountUser")
+		}
+	}
+	if len(associations) == 0 {
+		return q
+	}
+	return q.Eager(associations...)
+}
+
+// loadAccountIncludes completes the associations eager loading can not handle
+func loadAccountIncludes(tx *storage.Connection, accounts []*Account, includes []string) error {
+	for _, include := range includes {
+		if include != AccountRolePermissions {
+			continue
+		}
+		roles := []*Role{}
+		for _, account := range accounts {
+			for i := range account.Roles {
+				roles = append(roles, &account.Roles[i])
+			}
+		}
+		if err := loadPermissions(tx, roles...); err != nil {
+			return err
+		}
+	}
+	for _, account := range accounts {
+		account.AccountUser = activeAccountUsers(account.AccountUser, time.Now())
+	}
+	return nil
+}
+
+// FindAccountByIDWith finds an account and loads only the given associations
+func FindAccountByIDWith(tx *storage.Connection, id uuid.UUID, includes []string) (*Account, error) {
+	obj := &Account{}
+	if err := eagerAccounts(tx.Q(), includes).Where("id = ?", id).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding account")
+	}
+	if err := loadAccountIncludes(tx, []*Account{obj}, includes); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// FindAccountsOfUser returns the accounts the user is an active member or an owner of
+func FindAccountsOfUser(tx *storage.Connection, userID uuid.UUID) ([]*Account, error) {
+	accounts := []*Account{}
+	memberships := "SELECT account_id FROM " + AccountUser{}.TableName() + " WHERE user_id = ? AND (expires_at IS NULL OR expires_at > ?)"
+	// only the members are needed, roles are loaded separately
+	if err := eagerAccounts(tx.Q(), []string{AccountUsers}).Where("id IN ("+memberships+") OR JSON_SEARCH(raw_owner_ids, 'one', ?) IS NOT NULL", userID, time.Now(), userID.String()).Order("name ASC").All(&accounts); err != nil {
+		return nil, errors.Wrap(err, "error finding accounts of user")
+	}
+	for _, account := range accounts {
+		account.AccountUser = activeAccountUsers(account.AccountUser, time.Now())
+	}
+	return accounts, nil
+}
+
+// AccountMetaDataFilter prefixes filters on keys of the account metadata
+const AccountMetaDataFilter = "account_metadata."
+
+// filterAccounts narrows the query down to the accounts matching all filters
+func filterAccounts(q *pop.Query, filterParams *FilterParams) *pop.Query {
+	if filterParams == nil {
+		return q
+	}
+	for _, field := range filterParams.Fields {
+		switch {
+		case field.Name == "owner_id":
+			q = q.Where("JSON_SEARCH(raw_owner_ids, 'one', ?) IS NOT NULL", fmt.Sprint(field.Value))
+		case field.Name == "member_id":
+			q = q.Where("id IN (SELECT account_id FROM "+AccountUser{}.TableName()+" WHERE user_id = ? AND (expires_at IS NULL OR expires_at > ?))", field.Value, time.Now())
+		case strings.HasPrefix(field.Name, AccountMetaDataFilter):
+			path := `$."` + strings.TrimPrefix(field.Name, AccountMetaDataFilter) + `"`
+			q = q.Where("JSON_UNQUOTE(JSON_EXTRACT(raw_account_meta_data, ?)) = ?", path, field.Value)
+		default:
+			switch field.Op {
+			case Prefix:
+				q = q.Where(field.Name+" LIKE ?", escapeLike(fmt.Sprint(field.Value))+"%")
+			case Contains:
+				q = q.Where(field.Name+" LIKE ?", "%"+escapeLike(fmt.Sprint(field.Value))+"%")
+			case GreaterOrEqual:
+				q = q.Where(field.Name+" >= ?", field.Value)
+			case LessOrEqual:
+				q = q.Where(field.Name+" <= ?", field.Value)
+			default:
+				q = q.Where(field.Name+" = ?", field.Value)
+			}
+		}
+	}
+	return q
+}
+
+// FindAccounts searches for Accounts matching the filters and loads the given associations,
+// a userID limits them to the accounts the user is an active member or an owner of
+func FindAccounts(tx *storage.Connection, userID uuid.UUID, pageParams *Pagination, sortParams *SortParams, filterParams *FilterParams, includes []string) ([]*Account, error) {
 	accounts := []*Account{}
 	var err error
 
-	pop.Debug = true
 	q := tx.Q()
-	if userID.String() != "00000000-0000-0000-0000-000000000000" {
-		// UserID is not nil, so we have to query for the relations from
-		// account_user
-		q.RawQuery(`
-		SELECT
-			accounts.id as id,
-			accounts.name as name,
-			accounts.billing_name as billing_name,
-			accounts.billing_email as billing_email,
-			accounts.billing_details as billing_details,
-			accounts.billing_period as billing_period,
-			accounts.payment_method_id as payment_method_id,
-			accounts.raw_owner_ids as raw_owner_ids,
-			accounts.raw_account_meta_data as raw_account_meta_data,
-			accounts.created_at as created_at,
-			accounts.updated_at as updated_at
-		FROM
-			accounts_users as accounts_users
-			JOIN accounts ON accounts.id = accounts_users.account_id
-		WHERE
-			accounts_users.user_id = ?`, userID)
-
-		err = q.Eager("Roles").All(&accounts)
-		return accounts, err
+	if userID != uuid.Nil {
+		memberships := "SELECT account_id FROM " + AccountUser{}.TableName() + " WHERE user_id = ? AND (expires_at IS NULL OR expires_at > ?)"
+		q = q.Where("(id IN ("+memberships+") OR JSON_SEARCH(raw_owner_ids, 'one', ?) IS NOT NULL)", userID, time.Now(), userID.String())
+	}
+	q = filterAccounts(q, filterParams)
+
+	if pageParams != nil && pageParams.Keyset {
+		if err = eagerAccounts(pageParams.applyCursor(q, "id"), includes).All(&accounts); err != nil {
+			return nil, err
+		}
+		accounts = accounts[:pageParams.trim(len(accounts), func(i int) Cursor {
+			return Cursor{CreatedAt: accounts[i].CreatedAt, ID: accounts[i].ID}
+		})]
+		return accounts, loadAccountIncludes(tx, accounts, includes)
 	}
 
 	if sortParams != nil && len(sortParams.Fields) > 0 {
@@ -232,10 +378,13 @@ func FindAccounts(tx *storage.Connection, userID uuid.UUID, pageParams *Paginati
 	}
 
 	if pageParams != nil {
-		err = q.Paginate(int(pageParams.Page), int(pageParams.PerPage)).Eager("Roles").All(&accounts)
+		err = eagerAccounts(q.Paginate(int(pageParams.Page), int(pageParams.PerPage)), includes).All(&accounts)
 		pageParams.Count = uint64(q.Paginator.TotalEntriesSize)
 	} else {
-		err = q.Eager("Roles").All(&accounts)
+		err = eagerAccounts(q, includes).All(&accounts)
+	}
+	if err != nil {
+		return nil, err
 	}
-	return accounts, err
+	return accounts, loadAccountIncludes(tx, accounts, includes)
 }
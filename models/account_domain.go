@@ -0,0 +1,170 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// DomainVerificationPrefix starts the DNS TXT record which proves
+// that an account owns a domain
+const DomainVerificationPrefix = "delivc-team-verification="
+
+// AccountDomain is an email domain claimed by an account,
+// once verified users with an email of the domain join the account automatically
+type AccountDomain struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	AccountID         uuid.UUID  `json:"-" db:"account_id"`
+	RoleID            uuid.UUID  `json:"role_id" db:"role_id"`
+	Domain            string     `json:"domain" db:"domain"`
+	VerificationToken string     `json:"verification_token" db:"verification_token"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TableName returns the given tablename of the model
+func (AccountDomain) TableName() string {
+	tableName := "account_domains"
+
+	if namespace.GetNamespace() != "" {
+		return namespace.GetNamespace() + "_" + tableName
+	}
+
+	return tableName
+}
+
+// NormalizeDomain lowercases a domain and strips a leading `@`
+func NormalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@"), ".")
+}
+
+// EmailDomain returns the normalized domain of an email address
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return NormalizeDomain(email[at+1:])
+}
+
+// NewAccountDomain creates a new unverified AccountDomain with a random verification token
+// does not create!!! the domain in the database
+func NewAccountDomain(accountID, roleID uuid.UUID, domain string) (*AccountDomain, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error generating unique id")
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, errors.Wrap(err, "Error generating verification token")
+	}
+
+	accountDomain := &AccountDomain{
+		ID:                id,
+		AccountID:         accountID,
+		RoleID:            roleID,
+		Domain:            NormalizeDomain(domain),
+		VerificationToken: hex.EncodeToString(token),
+	}
+	return accountDomain, nil
+}
+
+// TXTRecord returns the DNS TXT record which verifies the domain
+func (d *AccountDomain) TXTRecord() string {
+	return DomainVerificationPrefix + d.VerificationToken
+}
+
+// IsVerified checks if the domain has been verified
+func (d *AccountDomain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// MatchesTXT checks if one of the TXT records of the domain proves the claim
+func (d *AccountDomain) MatchesTXT(records []string) bool {
+	for _, record := range records {
+		if strings.TrimSpace(record) == d.TXTRecord() {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify marks the domain as verified
+func (d *AccountDomain) Verify(tx *storage.Connection) error {
+	now := time.Now()
+	d.VerifiedAt = &now
+	return tx.UpdateOnly(d, "verified_at", "updated_at")
+}
+
+// FindAccountDomains returns all domains claimed by an account
+func FindAccountDomains(tx *storage.Connection, accountID uuid.UUID) ([]*AccountDomain, error) {
+	obj := []*AccountDomain{}
+	if err := tx.Q().Where("account_id = ?", accountID).Order("domain ASC").All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding account domains")
+	}
+	return obj, nil
+}
+
+// FindAccountDomainByAccountAndID returns a single domain of an account
+func FindAccountDomainByAccountAndID(tx *storage.Connection, accountID, id uuid.UUID) (*AccountDomain, error) {
+	obj := &AccountDomain{}
+	if err := tx.Q().Where("account_id = ? and id = ?", accountID, id).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountDomainNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding account domain")
+	}
+	return obj, nil
+}
+
+// IsEmailDomainVerified checks if the domain of the email is verified by the account
+func IsEmailDomainVerified(tx *storage.Connection, accountID uuid.UUID, email string) (bool, error) {
+	domain := EmailDomain(email)
+	if domain == "" {
+		return false, nil
+	}
+	exists, err := tx.Q().Where("account_id = ? and domain = ? and verified_at IS NOT NULL", accountID, domain).Exists(&AccountDomain{})
+	if err != nil {
+		return false, errors.Wrap(err, "error finding verified account domains")
+	}
+	return exists, nil
+}
+
+// FindVerifiedAccountDomains returns the verified claims of a domain
+func FindVerifiedAccountDomains(tx *storage.Connection, domain string) ([]*AccountDomain, error) {
+	obj := []*AccountDomain{}
+	if err := tx.Q().Where("domain = ? and verified_at IS NOT NULL", NormalizeDomain(domain)).All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding verified account domains")
+	}
+	return obj, nil
+}
+
+// DeleteAccountDomain removes a domain claim from storage
+func DeleteAccountDomain(tx *storage.Connection, id uuid.UUID) error {
+	return tx.Destroy(&AccountDomain{ID: id})
+}
+
+// JoinAccountByDomain attaches a user to the account of a verified domain
+// with the role of the domain
+func JoinAccountByDomain(tx *storage.Connection, domain *AccountDomain, userID uuid.UUID) (*AccountUser, error) {
+	now := time.Now()
+	member := &AccountUser{
+		AccountID:   domain.AccountID,
+		UserID:      userID,
+		RoleID:      domain.RoleID,
+		ConfirmedAt: &now,
+	}
+	if err := tx.Create(member); err != nil {
+		return nil, errors.Wrap(err, "Error attaching user to account")
+	}
+	return member, nil
+}
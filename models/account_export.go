@@ -0,0 +1,345 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// AccountExportVersion is the version of the export format,
+// imports of other versions are rejected
+const AccountExportVersion = 1
+
+// Conflict policies of an import if the account exists already
+const (
+	// ImportFail rejects the import
+	ImportFail = "fail"
+	// ImportReplace deletes the existing account first
+	ImportReplace = "replace"
+	// ImportCopy imports the account with a new ID
+	ImportCopy = "copy"
+)
+
+// AccountExport is a portable copy of an account, permissions are referenced by name
+// so the export can be imported into another environment
+type AccountExport struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Account    ExportedAccount  `json:"account"`
+	Owners     []uuid.UUID      `json:"owners"`
+	Roles      []ExportedRole   `json:"roles"`
+	Members    []ExportedMember `json:"members"`
+}
+
+// ExportedAccount holds the attributes of the account
+type ExportedAccount struct {
+	ID              uuid.UUID `json:"id"`
+	Aud             string    `json:"aud"`
+	Name            string    `json:"name"`
+	BillingName     string    `json:"billing_name,omitempty"`
+	BillingEmail    string    `json:"billing_email,omitempty"`
+	BillingDetails  string    `json:"billing_details,omitempty"`
+	BillingPeriod   string    `json:"billing_period,omitempty"`
+	AccountMetaData JSONMap   `json:"account_metadata,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// ExportedRole is a role with the names of its permissions
+type ExportedRole struct {
+	ID          uuid.UUID              `json:"id"`
+	Name        string                 `json:"name"`
+	Template    string                 `json:"template,omitempty"`
+	System      bool                   `json:"system"`
+	Permissions []string               `json:"permissions"`
+	Deny        []string               `json:"deny,omitempty"`
+	Conditions  map[string]*Conditions `json:"conditions,omitempty"`
+}
+
+// ExportedMember is a membership, RoleID points to a role of the export
+type ExportedMember struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	RoleID      uuid.UUID  `json:"role_id"`
+	InvitedAt   *time.Time `json:"invited_at,omitempty"`
+	InvitedBy   uuid.UUID  `json:"invited_by,omitempty"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// ImportResult describes the imported account
+type ImportResult struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Replaced  bool      `json:"replaced"`
+	// RoleIDs maps the role IDs of the export to the imported roles
+	RoleIDs map[uuid.UUID]uuid.UUID `json:"role_ids"`
+}
+
+// ImportConflictError is returned if the account of an import exists already
+type ImportConflictError struct {
+	AccountID uuid.UUID
+}
+
+func (e ImportConflictError) Error() string {
+	return "Account " + e.AccountID.String() + " exists already"
+}
+
+// ImportDataLossError is returned if replacing an account would delete
+// rows which are not part of the export
+type ImportDataLossError struct {
+	AccountID uuid.UUID
+	Rows      []string
+}
+
+func (e ImportDataLossError) Error() string {
+	return "Replacing account " + e.AccountID.String() + " would delete its " + strings.Join(e.Rows, ", ")
+}
+
+// InvalidImportError is returned if an export can not be imported
+type InvalidImportError struct {
+	Message string
+}
+
+func (e InvalidImportError) Error() string {
+	return e.Message
+}
+
+// ExportAccount collects everything needed to recreate an account
+func ExportAccount(tx *storage.Connection, accountID uuid.UUID) (*AccountExport, error) {
+	account, err := FindAccountByIDWith(tx, accountID, []string{AccountRoles, AccountUsers, AccountRolePermissions})
+	if err != nil {
+		return nil, err
+	}
+
+	export := &AccountExport{
+		Version:    AccountExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Account: ExportedAccount{
+			ID:              account.ID,
+			Aud:             account.Aud,
+			Name:            account.Name,
+			BillingName:     account.BillingName,
+			BillingEmail:    account.BillingEmail,
+			BillingDetails:  account.BillingDetails,
+			BillingPeriod:   account.BillingPeriod,
+			AccountMetaData: account.AccountMetaData,
+			CreatedAt:       account.CreatedAt,
+		},
+		Owners:  account.Owners(),
+		Roles:   []ExportedRole{},
+		Members: []ExportedMember{},
+	}
+
+	for _, role := range account.Roles {
+		exported := ExportedRole{
+			ID:          role.ID,
+			Name:        role.Name,
+			Template:    role.Template,
+			System:      role.System,
+			Permissions: role.Permissions.Names(),
+			Deny:        role.DeniedPermissions.Names(),
+			Conditions:  map[string]*Conditions{},
+		}
+		for _, permission := range append(append(Permissions{}, role.Permissions...), role.DeniedPermissions...) {
+			if permission.Conditions != nil {
+				exported.Conditions[permission.Name] = permission.Conditions
+			}
+		}
+		sort.Strings(exported.Permissions)
+		sort.Strings(exported.Deny)
+		export.Roles = append(export.Roles, exported)
+	}
+	sort.Slice(export.Roles, func(i, j int) bool { return export.Roles[i].Name < export.Roles[j].Name })
+
+	for _, member := range account.AccountUser {
+		export.Members = append(export.Members, ExportedMember{
+			UserID:      member.UserID,
+			RoleID:      member.RoleID,
+			InvitedAt:   member.InvitedAt,
+			InvitedBy:   member.InvitedBy,
+			ConfirmedAt: member.ConfirmedAt,
+			ExpiresAt:   member.ExpiresAt,
+		})
+	}
+	return export, nil
+}
+
+// Validate checks the export is complete and consistent before anything is imported
+func (e *AccountExport) Validate() error {
+	if e.Version != AccountExportVersion {
+		return InvalidImportError{Message: "Unsupported export version"}
+	}
+	if e.Account.Name == "" {
+		return InvalidImportError{Message: "Export contains no account name"}
+	}
+	if len(e.Owners) == 0 {
+		return InvalidImportError{Message: "Export contains no owners"}
+	}
+	roles := map[uuid.UUID]bool{}
+	for _, role := range e.Roles {
+		if roles[role.ID] {
+			return InvalidImportError{Message: "Role " + role.ID.String() + " is exported twice"}
+		}
+		roles[role.ID] = true
+	}
+	members := map[uuid.UUID]bool{}
+	for _, member := range e.Members {
+		if members[member.UserID] {
+			return InvalidImportError{Message: "Member " + member.UserID.String() + " is exported twice"}
+		}
+		members[member.UserID] = true
+		if !roles[member.RoleID] {
+			return InvalidImportError{Message: "Member " + member.UserID.String() + " has a role which is not part of the export"}
+		}
+	}
+	return nil
+}
+
+// unexportedRows returns which rows of an account the export does not contain,
+// they would be lost if the account was replaced
+func unexportedRows(tx *storage.Connection, accountID uuid.UUID) ([]string, error) {
+	rows := []struct {
+		model interface{}
+		what  string
+	}{
+		{&AccountDomain{}, "domains"},
+		{&SCIMToken{}, "SCIM tokens"},
+		{&SCIMUser{}, "provisioned users"},
+		{&RoleAssignment{}, "role assignments"},
+		{&AccessRequest{}, "access requests"},
+	}
+	found := []string{}
+	for _, row := range rows {
+		exists, err := tx.Q().Where("account_id = ?", accountID).Exists(row.model)
+		if err != nil {
+			return nil, errors.Wrap(err, "error finding "+row.what)
+		}
+		if exists {
+			found = append(found, row.what)
+		}
+	}
+	return found, nil
+}
+
+// permissionNames returns every permission the roles of the export refer to
+func (e *AccountExport) permissionNames() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, role := range e.Roles {
+		for _, name := range append(append([]string{}, role.Permissions...), role.Deny...) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// ImportAccount recreates an exported account, roles always get new IDs.
+// The policy decides what happens if the account exists already,
+// accounts with rows the export does not contain are never replaced
+func ImportAccount(tx *storage.Connection, instanceID uuid.UUID, export *AccountExport, policy string) (*ImportResult, error) {
+	if err := export.Validate(); err != nil {
+		return nil, err
+	}
+
+	unknown, err := UnknownPermissionNames(tx, export.permissionNames())
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		return nil, InvalidImportError{Message: "Permission " + unknown[0] + " is not registered"}
+	}
+
+	result := &ImportResult{AccountID: export.Account.ID, RoleIDs: map[uuid.UUID]uuid.UUID{}}
+	exists, err := tx.Q().Where("id = ?", export.Account.ID).Exists(&Account{})
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding account")
+	}
+	if exists {
+		switch policy {
+		case ImportReplace:
+			var lost []string
+			if lost, err = unexportedRows(tx, export.Account.ID); err != nil {
+				return nil, err
+			}
+			if len(lost) > 0 {
+				return nil, ImportDataLossError{AccountID: export.Account.ID, Rows: lost}
+			}
+			if _, err = DeleteAccount(tx, export.Account.ID); err != nil {
+				return nil, errors.Wrap(err, "error deleting account")
+			}
+			result.Replaced = true
+		case ImportCopy:
+			if result.AccountID, err = uuid.NewV4(); err != nil {
+				return nil, errors.Wrap(err, "Error generating unique id")
+			}
+		default:
+			return nil, ImportConflictError{AccountID: export.Account.ID}
+		}
+	}
+
+	account := &Account{
+		InstanceID:      instanceID,
+		ID:              result.AccountID,
+		Aud:             export.Account.Aud,
+		Name:            export.Account.Name,
+		BillingName:     export.Account.BillingName,
+		BillingEmail:    export.Account.BillingEmail,
+		BillingDetails:  export.Account.BillingDetails,
+		BillingPeriod:   export.Account.BillingPeriod,
+		AccountMetaData: export.Account.AccountMetaData,
+		CreatedAt:       export.Account.CreatedAt,
+	}
+	account.SetOwners(export.Owners)
+	if err = tx.Create(account); err != nil {
+		return nil, errors.Wrap(err, "error saving account")
+	}
+
+	for _, exported := range export.Roles {
+		role, err := NewRole(account.ID, exported.Name)
+		if err != nil {
+			return nil, err
+		}
+		role.Template = exported.Template
+		role.System = exported.System
+		if err = tx.Create(role); err != nil {
+			return nil, errors.Wrap(err, "error saving role")
+		}
+		if len(exported.Permissions) > 0 {
+			if err = role.UpdatePermissions(tx, exported.Permissions); err != nil {
+				return nil, err
+			}
+		}
+		if len(exported.Deny) > 0 {
+			if err = role.UpdateDeniedPermissions(tx, exported.Deny); err != nil {
+				return nil, err
+			}
+		}
+		if len(exported.Conditions) > 0 {
+			if err = role.SetConditions(tx, exported.Conditions); err != nil {
+				return nil, err
+			}
+		}
+		result.RoleIDs[exported.ID] = role.ID
+	}
+
+	for _, exported := range export.Members {
+		member := &AccountUser{
+			AccountID:   account.ID,
+			UserID:      exported.UserID,
+			RoleID:      result.RoleIDs[exported.RoleID],
+			InvitedAt:   exported.InvitedAt,
+			InvitedBy:   exported.InvitedBy,
+			ConfirmedAt: exported.ConfirmedAt,
+			ExpiresAt:   exported.ExpiresAt,
+		}
+		if err = tx.Create(member); err != nil {
+			return nil, errors.Wrap(err, "error saving member")
+		}
+	}
+	return result, nil
+}
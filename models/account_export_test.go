@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountExportValidate(t *testing.T) {
+	ownerID := uuid.Must(uuid.NewV4())
+	roleID := uuid.Must(uuid.NewV4())
+	valid := func() *AccountExport {
+		return &AccountExport{
+			Version: AccountExportVersion,
+			Account: ExportedAccount{ID: uuid.Must(uuid.NewV4()), Name: "Acme"},
+			Owners:  []uuid.UUID{ownerID},
+			Roles: []ExportedRole{
+				{ID: roleID, Name: "Admin", Permissions: []string{"account-edit", "account-users-invite"}},
+				{ID: uuid.Must(uuid.NewV4()), Name: "Viewer", Permissions: []string{"account-edit"}, Deny: []string{"account-destroy"}},
+			},
+			Members: []ExportedMember{{UserID: ownerID, RoleID: roleID}},
+		}
+	}
+
+	export := valid()
+	require.NoError(t, export.Validate())
+	assert.Equal(t, []string{"account-edit", "account-users-invite", "account-destroy"}, export.permissionNames())
+
+	data, err := json.Marshal(export)
+	require.NoError(t, err)
+	decoded := &AccountExport{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	assert.NoError(t, decoded.Validate())
+
+	export = valid()
+	export.Version = 2
+	assert.IsType(t, InvalidImportError{}, export.Validate())
+
+	export = valid()
+	export.Owners = nil
+	assert.Error(t, export.Validate())
+
+	export = valid()
+	export.Roles = append(export.Roles, export.Roles[0])
+	assert.Error(t, export.Validate())
+
+	export = valid()
+	export.Members[0].RoleID = uuid.Must(uuid.NewV4())
+	assert.Error(t, export.Validate())
+
+	export = valid()
+	export.Members = append(export.Members, ExportedMember{UserID: ownerID, RoleID: export.Roles[1].ID})
+	assert.Equal(t, InvalidImportError{Message: "Member " + ownerID.String() + " is exported twice"}, export.Validate())
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountOwners(t *testing.T) {
+	first := uuid.Must(uuid.NewV4())
+	second := uuid.Must(uuid.NewV4())
+
+	// owners are stored as uuid on create and read back as string
+	account := &Account{OwnerIDs: JSONMap{"10": second.String(), "0": first, "2": "invalid"}}
+	assert.Equal(t, []uuid.UUID{first, second}, account.Owners())
+	assert.True(t, account.IsOwner(first))
+	assert.True(t, account.IsOwner(second))
+
+	account.SetOwners([]uuid.UUID{second})
+	assert.Equal(t, JSONMap{"0": second.String()}, account.OwnerIDs)
+	assert.False(t, account.IsOwner(first))
+}
+
+func TestAccountIsLastOwner(t *testing.T) {
+	first := uuid.Must(uuid.NewV4())
+	second := uuid.Must(uuid.NewV4())
+
+	account := &Account{}
+	account.SetOwners([]uuid.UUID{first, second})
+	assert.False(t, account.IsLastOwner(first))
+
+	// once the other owner left, the remaining one has to stay
+	account.SetOwners([]uuid.UUID{second})
+	assert.True(t, account.IsLastOwner(second))
+	assert.False(t, account.IsLastOwner(first))
+
+	assert.False(t, (&Account{}).IsLastOwner(first))
+}
+
+func TestPickSuccessor(t *testing.T) {
+	owner := uuid.Must(uuid.NewV4())
+	invited := uuid.Must(uuid.NewV4())
+	viewer := uuid.Must(uuid.NewV4())
+	admin := uuid.Must(uuid.NewV4())
+	adminRole := uuid.Must(uuid.NewV4())
+	viewerRole := uuid.Must(uuid.NewV4())
+	now := time.Now()
+
+	members := []*AccountUser{
+		{UserID: owner, RoleID: adminRole},
+		{UserID: invited, RoleID: adminRole, InvitedAt: &now},
+		{UserID: viewer, RoleID: viewerRole},
+		{UserID: admin, RoleID: adminRole, InvitedAt: &now, ConfirmedAt: &now},
+	}
+	adminRoles := map[uuid.UUID]bool{adminRole: true}
+
+	assert.Equal(t, &admin, pickSuccessor(members, adminRoles, owner))
+	// without an accepted admin the account is orphaned
+	assert.Nil(t, pickSuccessor(members[:3], adminRoles, owner))
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/delivc/team/storage"
@@ -19,6 +20,9 @@ type AccountUser struct {
 	ConfirmedAt *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
 	InvitedAt   *time.Time `json:"invited_at,omitempty" db:"invited_at"`
 	InvitedBy   uuid.UUID  `json:"invited_by,omitempty" db:"invited_by"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // TableName returns the given tablename of the model
@@ -32,6 +36,35 @@ func (AccountUser) TableName() string {
 	return tableName
 }
 
+// IsExpired checks if the membership has run out at the given time
+func (au *AccountUser) IsExpired(now time.Time) bool {
+	return au.ExpiresAt != nil && !au.ExpiresAt.After(now)
+}
+
+// IsPending checks if the membership is an invitation which is not accepted yet
+func (au *AccountUser) IsPending() bool {
+	return au.InvitedAt != nil && au.ConfirmedAt == nil
+}
+
+// Confirm accepts the invitation of the member
+func (au *AccountUser) Confirm(tx *storage.Connection) error {
+	now := time.Now()
+	au.ConfirmedAt = &now
+	return tx.UpdateOnly(au, "confirmed_at", "updated_at")
+}
+
+// UpdateRole changes the account wide role of the member
+func (au *AccountUser) UpdateRole(tx *storage.Connection, roleID uuid.UUID) error {
+	au.RoleID = roleID
+	return tx.UpdateOnly(au, "role_id", "updated_at")
+}
+
+// UpdateExpiresAt changes when the membership runs out, nil keeps it forever
+func (au *AccountUser) UpdateExpiresAt(tx *storage.Connection, expiresAt *time.Time) error {
+	au.ExpiresAt = expiresAt
+	return tx.UpdateOnly(au, "expires_at", "updated_at")
+}
+
 // AttachUserToAccount attaches a user to given account
 func AttachUserToAccount(tx *storage.Connection, userID uuid.UUID, accountID uuid.UUID, roleID uuid.UUID) error {
 	relation := AccountUser{
@@ -44,3 +77,119 @@ func AttachUserToAccount(tx *storage.Connection, userID uuid.UUID, accountID uui
 	}
 	return nil
 }
+
+// InviteUserToAccount attaches a user to an account as invited member,
+// the membership is confirmed once the user accepts
+func InviteUserToAccount(tx *storage.Connection, accountID, userID, roleID, invitedBy uuid.UUID) (*AccountUser, error) {
+	now := time.Now()
+	member := &AccountUser{
+		AccountID: accountID,
+		UserID:    userID,
+		RoleID:    roleID,
+		InvitedAt: &now,
+		InvitedBy: invitedBy,
+	}
+	if err := tx.Create(member); err != nil {
+		return nil, errors.Wrap(err, "Error inviting user to account")
+	}
+	return member, nil
+}
+
+// activeAccountUsers drops memberships which have run out
+func activeAccountUsers(users []AccountUser, now time.Time) []AccountUser {
+	active := []AccountUser{}
+	for _, user := range users {
+		if !user.IsExpired(now) {
+			active = append(active, user)
+		}
+	}
+	return active
+}
+
+// FindAccountUser returns the active membership of a user within an account
+func FindAccountUser(tx *storage.Connection, accountID uuid.UUID, userID uuid.UUID) (*AccountUser, error) {
+	obj := &AccountUser{}
+	if err := tx.Q().Where("account_id = ? and user_id = ? and (expires_at IS NULL or expires_at > ?)", accountID, userID, time.Now()).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, AccountUserNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding account user")
+	}
+	return obj, nil
+}
+
+// HasAccountUser checks if a user has a membership within an account,
+// including memberships which have run out but are not removed yet
+func HasAccountUser(tx *storage.Connection, accountID uuid.UUID, userID uuid.UUID) (bool, error) {
+	count, err := tx.Q().Where("account_id = ? and user_id = ?", accountID, userID).Count(&AccountUser{})
+	if err != nil {
+		return false, errors.Wrap(err, "error counting account users")
+	}
+	return count > 0, nil
+}
+
+// ClearExpiredAccountUser removes the membership of a user which has run out
+// but is not swept yet, so the user can join again. True is returned if the user
+// has an active or pending membership, which is kept
+func ClearExpiredAccountUser(tx *storage.Connection, accountID uuid.UUID, userID uuid.UUID) (bool, error) {
+	members := []*AccountUser{}
+	if err := tx.Q().Where("account_id = ? and user_id = ?", accountID, userID).All(&members); err != nil {
+		return false, errors.Wrap(err, "error finding account user")
+	}
+	now := time.Now()
+	for _, member := range members {
+		if !member.IsExpired(now) {
+			return true, nil
+		}
+	}
+	if len(members) == 0 {
+		return false, nil
+	}
+	return false, DeleteAccountUser(tx, accountID, userID)
+}
+
+// FindAccountUsers returns the active members of an account
+func FindAccountUsers(tx *storage.Connection, accountID uuid.UUID) ([]*AccountUser, error) {
+	obj := []*AccountUser{}
+	if err := tx.Q().Where("account_id = ? and (expires_at IS NULL or expires_at > ?)", accountID, time.Now()).Order("created_at ASC").All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding account users")
+	}
+	return obj, nil
+}
+
+// FindAccountUsersPage returns one page of the active members of an account
+func FindAccountUsersPage(tx *storage.Connection, accountID uuid.UUID, pageParams *Pagination) ([]*AccountUser, error) {
+	obj := []*AccountUser{}
+	q := tx.Q().Where("account_id = ? and (expires_at IS NULL or expires_at > ?)", accountID, time.Now())
+	if err := pageParams.applyCursor(q, "user_id").All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding account users")
+	}
+	return obj[:pageParams.trim(len(obj), func(i int) Cursor {
+		return Cursor{CreatedAt: obj[i].CreatedAt, ID: obj[i].UserID}
+	})], nil
+}
+
+// DeleteAccountUser removes the membership of a user
+// together with its resource scoped role assignments
+func DeleteAccountUser(tx *storage.Connection, accountID uuid.UUID, userID uuid.UUID) error {
+	tableName := AccountUser{}.TableName()
+	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE account_id = ? AND user_id = ?", accountID, userID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting account user")
+	}
+	return DeleteRoleAssignmentsOfUser(tx, accountID, userID)
+}
+
+// DeleteExpiredAccountUsers removes all memberships which have run out
+// and returns them
+func DeleteExpiredAccountUsers(tx *storage.Connection, now time.Time) ([]*AccountUser, error) {
+	expired := []*AccountUser{}
+	if err := tx.Q().Where("expires_at IS NOT NULL and expires_at <= ?", now).All(&expired); err != nil {
+		return nil, errors.Wrap(err, "error finding expired account users")
+	}
+	for _, user := range expired {
+		if err := tx.Destroy(user); err != nil {
+			return nil, errors.Wrap(err, "error deleting expired account user")
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditAction is the action an audit entry records
+type AuditAction string
+
+// Audit actions
+const (
+	MembershipExpiredAction     AuditAction = "membership_expired"
+	RoleAssignmentExpiredAction AuditAction = "role_assignment_expired"
+	AccessRequestedAction       AuditAction = "access_requested"
+	AccessRequestApprovedAction AuditAction = "access_request_approved"
+	AccessRequestDeniedAction   AuditAction = "access_request_denied"
+	DomainVerifiedAction        AuditAction = "domain_verified"
+	DomainJoinedAction          AuditAction = "domain_joined"
+	SCIMTokenCreatedAction      AuditAction = "scim_token_created"
+	SCIMUserProvisionedAction   AuditAction = "scim_user_provisioned"
+	SCIMUserUpdatedAction       AuditAction = "scim_user_updated"
+	SCIMUserDeprovisionedAction AuditAction = "scim_user_deprovisioned"
+	SCIMUserLinkedAction        AuditAction = "scim_user_linked"
+	UserRemovedAction           AuditAction = "user_removed"
+	OwnershipTransferredAction  AuditAction = "ownership_transferred"
+	AccountOrphanedAction       AuditAction = "account_orphaned"
+	MemberLeftAction            AuditAction = "member_left"
+	InvitationAcceptedAction    AuditAction = "invitation_accepted"
+	UserErasedAction            AuditAction = "user_erased"
+)
+
+// AuditLogEntry records a change of an account, its members or their access
+type AuditLogEntry struct {
+	InstanceID uuid.UUID   `json:"-" db:"instance_id"`
+	ID         uuid.UUID   `json:"id" db:"id"`
+	AccountID  uuid.UUID   `json:"account_id" db:"account_id"`
+	ActorID    uuid.UUID   `json:"actor_id" db:"actor_id"`
+	SubjectID  uuid.UUID   `json:"subject_id" db:"subject_id"`
+	Action     AuditAction `json:"action" db:"action"`
+	Payload    JSONMap     `json:"payload" db:"payload"`
+	CreatedAt  time.Time   `json:"created_at" db:"created_at"`
+}
+
+// TableName returns the given tablename of the model
+func (AuditLogEntry) TableName() string {
+	tableName := "audit_log_entries"
+
+	if namespace.GetNamespace() != "" {
+		return namespace.GetNamespace() + "_" + tableName
+	}
+
+	return tableName
+}
+
+// NewAuditLogEntry saves an audit entry for an action of the actor on the subject.
+// Actions of the system itself use uuid.Nil as actor
+func NewAuditLogEntry(tx *storage.Connection, instanceID, accountID, actorID, subjectID uuid.UUID, action AuditAction, payload map[string]interface{}) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return errors.Wrap(err, "Error generating unique id")
+	}
+
+	entry := &AuditLogEntry{
+		InstanceID: instanceID,
+		ID:         id,
+		AccountID:  accountID,
+		ActorID:    actorID,
+		SubjectID:  subjectID,
+		Action:     action,
+		Payload:    payload,
+	}
+
+	if err := tx.Create(entry); err != nil {
+		return errors.Wrap(err, "Error saving audit log entry")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"component":  "audit",
+		"action":     action,
+		"account_id": accountID,
+		"actor_id":   actorID,
+		"subject_id": subjectID,
+	}).Info("audit event")
+	return nil
+}
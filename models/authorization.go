@@ -0,0 +1,155 @@
+package models
+
+import (
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+)
+
+// Reasons of an authorization decision,
+// in the order of their precedence
+const (
+	ReasonSuperAdmin = "super_admin"
+	ReasonOwner      = "owner"
+	ReasonDenied     = "denied"
+	ReasonGranted    = "granted"
+	// ReasonConditionFailed is given if a grant exists,
+	// but its conditions are not satisfied by the request
+	ReasonConditionFailed = "condition_failed"
+	ReasonNoGrant         = "no_grant"
+)
+
+// Decision is the outcome of a permission check
+type Decision struct {
+	Permission string     `json:"permission"`
+	Allowed    bool       `json:"allowed"`
+	Reason     string     `json:"reason"`
+	RoleID     *uuid.UUID `json:"role_id,omitempty"`
+	RoleName   string     `json:"role_name,omitempty"`
+	// Grant is the permission of the role which matched,
+	// eg. `spaces-*` for `spaces-edit`
+	Grant string `json:"grant,omitempty"`
+}
+
+// HasPermissionTo checks if given user is inside a role with request permission
+func (a *Account) HasPermissionTo(tx *storage.Connection, permission string, userID uuid.UUID) bool {
+	return a.HasPermissionOn(tx, permission, userID, nil)
+}
+
+// HasPermissionOn checks if given user has the requested permission,
+// either account wide or through a role assigned for the given resource.
+// A nil resource only takes account wide roles into account.
+// Without a request at hand conditional grants never apply
+func (a *Account) HasPermissionOn(tx *storage.Connection, permission string, userID uuid.UUID, resource *Resource) bool {
+	return a.Authorize(tx, permission, userID, resource, nil).Allowed
+}
+
+// Authorize decides if the user has the requested permission.
+// A deny rule of any role of the user wins over grants of the other roles,
+// conditions of rules are evaluated against the access context
+func (a *Account) Authorize(tx *storage.Connection, permission string, userID uuid.UUID, resource *Resource, access *AccessContext) Decision {
+	roles, err := a.rolesOf(tx, userID, resource)
+	if err != nil {
+		return Decision{Permission: permission, Reason: ReasonNoGrant}
+	}
+	return a.decide(roles, permission, access)
+}
+
+// EffectivePermissions decides every given permission for the user
+func (a *Account) EffectivePermissions(tx *storage.Connection, permissions []string, userID uuid.UUID, resource *Resource, access *AccessContext) ([]Decision, error) {
+	roles, err := a.rolesOf(tx, userID, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := []Decision{}
+	for _, permission := range permissions {
+		decisions = append(decisions, a.decide(roles, permission, access))
+	}
+	return decisions, nil
+}
+
+// rolesOf returns the roles the user has within the account,
+// plus the ones assigned for the given resource
+func (a *Account) rolesOf(tx *storage.Connection, userID uuid.UUID, resource *Resource) ([]*Role, error) {
+	// resource assignments only count for active members,
+	// invitations grant nothing until they are accepted
+	roleIDs := map[uuid.UUID]bool{}
+	for _, user := range activeAccountUsers(a.AccountUser, time.Now()) {
+		if user.UserID == userID && !user.IsPending() {
+			roleIDs[user.RoleID] = true
+		}
+	}
+
+	if resource != nil && len(roleIDs) > 0 {
+		assignments, err := FindRoleAssignmentsForResource(tx, a.ID, userID, *resource)
+		if err != nil {
+			return nil, err
+		}
+		for _, assignment := range assignments {
+			roleIDs[assignment.RoleID] = true
+		}
+	}
+
+	if len(roleIDs) == 0 {
+		return []*Role{}, nil
+	}
+
+	// get related roles with permissions
+	roles, err := FindRolesByAccount(tx, a.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*Role{}
+	for _, role := range roles {
+		if roleIDs[role.ID] {
+			result = append(result, role)
+		}
+	}
+	return result, nil
+}
+
+func (a *Account) decide(roles []*Role, permission string, access *AccessContext) Decision {
+	for _, role := range roles {
+		for _, rperm := range role.DeniedPermissions {
+			if PermissionMatches(rperm.Name, permission) && rperm.Conditions.DenySatisfied(a, access) {
+				return roleDecision(permission, false, ReasonDenied, role, rperm.Name)
+			}
+		}
+	}
+
+	var failed *Decision
+	for _, role := range roles {
+		for _, rperm := range role.Permissions {
+			if !PermissionMatches(rperm.Name, permission) {
+				continue
+			}
+			if rperm.Conditions.Satisfied(a, access) {
+				return roleDecision(permission, true, ReasonGranted, role, rperm.Name)
+			}
+			if failed == nil {
+				decision := roleDecision(permission, false, ReasonConditionFailed, role, rperm.Name)
+				failed = &decision
+			}
+		}
+	}
+	if failed != nil {
+		return *failed
+	}
+
+	return Decision{Permission: permission, Reason: ReasonNoGrant}
+}
+
+func roleDecision(permission string, allowed bool, reason string, role *Role, grant string) Decision {
+	roleID := role.ID
+	return Decision{
+		Permission: permission,
+		Allowed:    allowed,
+		Reason:     reason,
+		RoleID:     &roleID,
+		RoleName:   role.Name,
+		Grant:      grant,
+	}
+}
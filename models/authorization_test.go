@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRole(t *testing.T, name string, granted []string, denied []string) *Role {
+	role, err := NewRole(uuid.Nil, name)
+	require.NoError(t, err)
+	for _, permission := range granted {
+		role.Permissions = append(role.Permissions, Permission{Name: permission})
+	}
+	for _, permission := range denied {
+		role.DeniedPermissions = append(role.DeniedPermissions, Permission{Name: permission})
+	}
+	return role
+}
+
+func TestDecideDenyWinsOverGrants(t *testing.T) {
+	editor := testRole(t, "Editor", []string{"spaces-*"}, []string{"spaces-destroy-content"})
+	admin := testRole(t, "Admin", []string{"*"}, nil)
+	roles := []*Role{admin, editor}
+
+	decision := (&Account{}).decide(roles, "spaces-destroy-content", nil)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonDenied, decision.Reason)
+	assert.Equal(t, "Editor", decision.RoleName)
+
+	decision = (&Account{}).decide(roles, "spaces-edit-content", nil)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, ReasonGranted, decision.Reason)
+	assert.Equal(t, "*", decision.Grant)
+}
+
+func TestDecideWithoutGrant(t *testing.T) {
+	viewer := testRole(t, "Viewer", nil, nil)
+
+	decision := (&Account{}).decide([]*Role{viewer}, "account-edit", nil)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonNoGrant, decision.Reason)
+	assert.Nil(t, decision.RoleID)
+}
+
+func TestRolesOfSkipsPendingInvitations(t *testing.T) {
+	userID := uuid.Must(uuid.NewV4())
+	now := time.Now()
+	account := &Account{AccountUser: []AccountUser{{UserID: userID, RoleID: uuid.Must(uuid.NewV4()), InvitedAt: &now}}}
+
+	// without a role the storage is not queried
+	roles, err := account.rolesOf(nil, userID, nil)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+	assert.True(t, account.AccountUser[0].IsPending())
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// AccessContext holds the attributes of a request
+// conditions of permissions are evaluated against
+type AccessContext struct {
+	Time time.Time
+	IP   net.IP
+	Aud  string
+}
+
+// Conditions restrict when a permission of a role applies,
+// all given conditions must be satisfied
+type Conditions struct {
+	TimeWindows []TimeWindow `json:"time_windows,omitempty"`
+	CIDRs       []string     `json:"cidrs,omitempty"`
+	// Attributes must match the account_metadata of the account
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Audiences  []string               `json:"audiences,omitempty"`
+}
+
+// TimeWindow is a daily time range, eg. business hours.
+// A window ending before it starts spans midnight
+type TimeWindow struct {
+	// Days are `mon` to `sun`, empty means every day
+	Days     []string `json:"days,omitempty"`
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Timezone string   `json:"timezone,omitempty"`
+}
+
+const clockFormat = "15:04"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Value returns the conditions as string
+func (c *Conditions) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return driver.Value(""), err
+	}
+	return driver.Value(string(data)), nil
+}
+
+// Scan scans the json conditions
+func (c *Conditions) Scan(src interface{}) error {
+	var source []byte
+	switch v := src.(type) {
+	case string:
+		source = []byte(v)
+	case []byte:
+		source = v
+	default:
+		return errors.New("Invalid data type for Conditions")
+	}
+
+	if len(source) == 0 {
+		source = []byte("{}")
+	}
+	return json.Unmarshal(source, c)
+}
+
+// Validate checks the conditions can be evaluated
+func (c *Conditions) Validate() error {
+	for _, window := range c.TimeWindows {
+		if _, err := time.Parse(clockFormat, window.Start); err != nil {
+			return errors.Errorf("invalid start '%v' of time window, expected HH:MM", window.Start)
+		}
+		if _, err := time.Parse(clockFormat, window.End); err != nil {
+			return errors.Errorf("invalid end '%v' of time window, expected HH:MM", window.End)
+		}
+		if _, err := time.LoadLocation(window.Timezone); err != nil {
+			return errors.Errorf("invalid timezone '%v' of time window", window.Timezone)
+		}
+		for _, day := range window.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return errors.Errorf("invalid day '%v' of time window, expected mon to sun", day)
+			}
+		}
+	}
+	for _, cidr := range c.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return errors.Errorf("invalid cidr '%v'", cidr)
+		}
+	}
+	return nil
+}
+
+// Satisfied evaluates the conditions for a request on the account,
+// missing request attributes never satisfy a condition
+func (c *Conditions) Satisfied(account *Account, access *AccessContext) bool {
+	if c == nil {
+		return true
+	}
+	if access == nil {
+		return len(c.TimeWindows) == 0 && len(c.CIDRs) == 0 && len(c.Audiences) == 0 && c.attributesSatisfied(account)
+	}
+	return c.timeSatisfied(access.Time) &&
+		c.ipSatisfied(access.IP) &&
+		c.audienceSatisfied(access.Aud) &&
+		c.attributesSatisfied(account)
+}
+
+// DenySatisfied evaluates the conditions of a deny rule, which fails closed:
+// request attributes which are missing do not keep the rule from applying
+func (c *Conditions) DenySatisfied(account *Account, access *AccessContext) bool {
+	if c == nil {
+		return true
+	}
+	if access == nil {
+		access = &AccessContext{}
+	}
+	return (access.Time.IsZero() || c.timeSatisfied(access.Time)) &&
+		(access.IP == nil || c.ipSatisfied(access.IP)) &&
+		(access.Aud == "" || c.audienceSatisfied(access.Aud)) &&
+		c.attributesSatisfied(account)
+}
+
+func (c *Conditions) timeSatisfied(now time.Time) bool {
+	if len(c.TimeWindows) == 0 {
+		return true
+	}
+	for _, window := range c.TimeWindows {
+		if window.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w TimeWindow) contains(now time.Time) bool {
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return false
+	}
+	start, err := time.Parse(clockFormat, w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(clockFormat, w.End)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	day := local.Weekday()
+	var inside bool
+	if from <= to {
+		inside = minute >= from && minute < to
+	} else {
+		// spans midnight, the early part belongs to the window of the previous day
+		inside = minute >= from || minute < to
+		if minute < to {
+			day = (day + 6) % 7
+		}
+	}
+	if !inside {
+		return false
+	}
+
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekday, ok := weekdays[strings.ToLower(name)]; ok && weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Conditions) ipSatisfied(ip net.IP) bool {
+	if len(c.CIDRs) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range c.CIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Conditions) audienceSatisfied(aud string) bool {
+	if len(c.Audiences) == 0 {
+		return true
+	}
+	for _, audience := range c.Audiences {
+		if audience == aud {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Conditions) attributesSatisfied(account *Account) bool {
+	for key, expected := range c.Attributes {
+		if account == nil || account.AccountMetaData == nil {
+			return false
+		}
+		if value, ok := account.AccountMetaData[key]; !ok || !reflect.DeepEqual(value, expected) {
+			return false
+		}
+	}
+	return true
+}
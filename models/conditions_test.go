@@ -0,0 +1,87 @@
+package models
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionsTimeWindows(t *testing.T) {
+	c := &Conditions{TimeWindows: []TimeWindow{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "17:00", Timezone: "Europe/Berlin"},
+	}}
+	require.NoError(t, c.Validate())
+
+	// Wednesday 10:00 in Berlin
+	assert.True(t, c.Satisfied(nil, &AccessContext{Time: time.Date(2020, 3, 25, 9, 0, 0, 0, time.UTC)}))
+	// Wednesday 18:00 in Berlin
+	assert.False(t, c.Satisfied(nil, &AccessContext{Time: time.Date(2020, 3, 25, 17, 0, 0, 0, time.UTC)}))
+	// Saturday 10:00 in Berlin
+	assert.False(t, c.Satisfied(nil, &AccessContext{Time: time.Date(2020, 3, 28, 9, 0, 0, 0, time.UTC)}))
+	// no request at hand
+	assert.False(t, c.Satisfied(nil, nil))
+}
+
+func TestConditionsTimeWindowSpanningMidnight(t *testing.T) {
+	c := &Conditions{TimeWindows: []TimeWindow{{Days: []string{"fri"}, Start: "22:00", End: "06:00"}}}
+
+	// Friday 23:00 and Saturday 05:00 belong to the window of friday
+	assert.True(t, c.Satisfied(nil, &AccessContext{Time: time.Date(2020, 3, 27, 23, 0, 0, 0, time.UTC)}))
+	assert.True(t, c.Satisfied(nil, &AccessContext{Time: time.Date(2020, 3, 28, 5, 0, 0, 0, time.UTC)}))
+	// Friday 05:00 belongs to the window of thursday
+	assert.False(t, c.Satisfied(nil, &AccessContext{Time: time.Date(2020, 3, 27, 5, 0, 0, 0, time.UTC)}))
+}
+
+func TestConditionsCIDRsAndAttributes(t *testing.T) {
+	c := &Conditions{
+		CIDRs:      []string{"10.0.0.0/8", "192.168.1.0/24"},
+		Attributes: map[string]interface{}{"plan": "enterprise"},
+	}
+	require.NoError(t, c.Validate())
+
+	enterprise := &Account{AccountMetaData: JSONMap{"plan": "enterprise"}}
+	free := &Account{AccountMetaData: JSONMap{"plan": "free"}}
+
+	assert.True(t, c.Satisfied(enterprise, &AccessContext{IP: net.ParseIP("10.1.2.3")}))
+	assert.False(t, c.Satisfied(enterprise, &AccessContext{IP: net.ParseIP("8.8.8.8")}))
+	assert.False(t, c.Satisfied(free, &AccessContext{IP: net.ParseIP("10.1.2.3")}))
+	assert.False(t, c.Satisfied(enterprise, &AccessContext{}))
+}
+
+func TestConditionsValidate(t *testing.T) {
+	assert.Error(t, (&Conditions{CIDRs: []string{"10.0.0.1"}}).Validate())
+	assert.Error(t, (&Conditions{TimeWindows: []TimeWindow{{Start: "9", End: "17:00"}}}).Validate())
+	assert.Error(t, (&Conditions{TimeWindows: []TimeWindow{{Start: "09:00", End: "17:00", Days: []string{"monday"}}}}).Validate())
+	assert.Error(t, (&Conditions{TimeWindows: []TimeWindow{{Start: "09:00", End: "17:00", Timezone: "Mars/Base"}}}).Validate())
+}
+
+func TestDecideWithFailedCondition(t *testing.T) {
+	role := testRole(t, "Editor", []string{"spaces-edit-content"}, nil)
+	role.Permissions[0].Conditions = &Conditions{Audiences: []string{"app.delivc.com"}}
+
+	decision := (&Account{}).decide([]*Role{role}, "spaces-edit-content", &AccessContext{Aud: "shop.delivc.com"})
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonConditionFailed, decision.Reason)
+
+	decision = (&Account{}).decide([]*Role{role}, "spaces-edit-content", &AccessContext{Aud: "app.delivc.com"})
+	assert.True(t, decision.Allowed)
+}
+
+func TestDecideWithConditionalDenyFailsClosed(t *testing.T) {
+	role := testRole(t, "Editor", []string{"spaces-*"}, []string{"spaces-delete"})
+	role.DeniedPermissions[0].Conditions = &Conditions{CIDRs: []string{"10.0.0.0/8"}}
+
+	// without request attributes the deny rule applies
+	decision := (&Account{}).decide([]*Role{role}, "spaces-delete", nil)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, ReasonDenied, decision.Reason)
+
+	decision = (&Account{}).decide([]*Role{role}, "spaces-delete", &AccessContext{IP: net.ParseIP("10.1.2.3")})
+	assert.Equal(t, ReasonDenied, decision.Reason)
+
+	decision = (&Account{}).decide([]*Role{role}, "spaces-delete", &AccessContext{IP: net.ParseIP("8.8.8.8")})
+	assert.True(t, decision.Allowed)
+}
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"strings"
+	"time"
+
 	"github.com/delivc/team/storage"
 	"github.com/gobuffalo/pop/v5"
+	"github.com/gofrs/uuid"
 )
 
 // Pagination model
@@ -10,6 +14,40 @@ type Pagination struct {
 	Page    uint64
 	PerPage uint64
 	Count   uint64
+	// Keyset pages through the rows after Cursor instead of skipping an offset,
+	// the pages stay stable while rows are inserted
+	Keyset bool
+	Cursor *Cursor
+	// Next is the cursor of the following page, nil on the last page
+	Next *Cursor
+}
+
+// Cursor points to the last row of a page,
+// rows are ordered by their creation and their ID
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// applyCursor orders the query from new to old and narrows it down to the rows
+// after the cursor, one row more than requested tells if there is another page
+func (p *Pagination) applyCursor(q *pop.Query, idColumn string) *pop.Query {
+	if p.Cursor != nil {
+		q = q.Where("(created_at < ? OR (created_at = ? AND "+idColumn+" < ?))", p.Cursor.CreatedAt, p.Cursor.CreatedAt, p.Cursor.ID)
+	}
+	return q.Order("created_at DESC, " + idColumn + " DESC").Limit(int(p.PerPage) + 1)
+}
+
+// trim drops the extra row of applyCursor, remembers where the next page starts
+// and returns the number of rows on this page
+func (p *Pagination) trim(loaded int, cursorOf func(i int) Cursor) int {
+	if loaded <= int(p.PerPage) {
+		p.Next = nil
+		return loaded
+	}
+	next := cursorOf(int(p.PerPage) - 1)
+	p.Next = &next
+	return int(p.PerPage)
 }
 
 // Offset for pagination
@@ -29,6 +67,9 @@ const Descending SortDirection = "DESC"
 // CreatedAt is a constant! ;O
 const CreatedAt = "created_at"
 
+// UpdatedAt is the time of the last change
+const UpdatedAt = "updated_at"
+
 // SortParams ?field,field,field
 type SortParams struct {
 	Fields []SortField
@@ -40,6 +81,35 @@ type SortField struct {
 	Dir  SortDirection
 }
 
+// FilterOperator compares a field with a value
+type FilterOperator string
+
+// Filter operators
+const (
+	Equals         FilterOperator = "eq"
+	Prefix         FilterOperator = "prefix"
+	Contains       FilterOperator = "contains"
+	GreaterOrEqual FilterOperator = "gte"
+	LessOrEqual    FilterOperator = "lte"
+)
+
+// FilterParams ?filter=field:operator:value
+type FilterParams struct {
+	Fields []FilterField
+}
+
+// FilterField filter by what
+type FilterField struct {
+	Name  string
+	Op    FilterOperator
+	Value interface{}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // TruncateAll truncates all models
 func TruncateAll(conn *storage.Connection) error {
 	return conn.Transaction(func(tx *storage.Connection) error {
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginationTrim(t *testing.T) {
+	rows := []Cursor{}
+	for i := 0; i < 3; i++ {
+		rows = append(rows, Cursor{CreatedAt: time.Now(), ID: uuid.Must(uuid.NewV4())})
+	}
+	cursorOf := func(i int) Cursor { return rows[i] }
+
+	p := &Pagination{Keyset: true, PerPage: 2}
+	assert.Equal(t, 2, p.trim(3, cursorOf))
+	assert.Equal(t, &rows[1], p.Next)
+
+	assert.Equal(t, 2, p.trim(2, cursorOf))
+	assert.Nil(t, p.Next)
+}
@@ -3,7 +3,7 @@ package models
 // IsNotFoundError returns whether an error represents a "not found" error.
 func IsNotFoundError(err error) bool {
 	switch err.(type) {
-	case AccountNotFoundError:
+	case AccountNotFoundError, AccountUserNotFoundError, RoleNotFoundError, RoleAssignmentNotFoundError, AccessRequestNotFoundError, AccountDomainNotFoundError, SCIMTokenNotFoundError, SCIMUserNotFoundError:
 		return true
 	}
 	return false
@@ -29,3 +29,45 @@ type RoleNotFoundError struct{}
 func (e RoleNotFoundError) Error() string {
 	return "Role not found"
 }
+
+// RoleAssignmentNotFoundError represents when a role assignment is not found.
+type RoleAssignmentNotFoundError struct{}
+
+func (e RoleAssignmentNotFoundError) Error() string {
+	return "Role assignment not found"
+}
+
+// AccountUserNotFoundError represents when a member of an account is not found.
+type AccountUserNotFoundError struct{}
+
+func (e AccountUserNotFoundError) Error() string {
+	return "Member not found"
+}
+
+// AccessRequestNotFoundError represents when an access request is not found.
+type AccessRequestNotFoundError struct{}
+
+func (e AccessRequestNotFoundError) Error() string {
+	return "Access request not found"
+}
+
+// AccountDomainNotFoundError represents when a claimed domain is not found.
+type AccountDomainNotFoundError struct{}
+
+func (e AccountDomainNotFoundError) Error() string {
+	return "Domain not found"
+}
+
+// SCIMTokenNotFoundError represents when a SCIM token is not found.
+type SCIMTokenNotFoundError struct{}
+
+func (e SCIMTokenNotFoundError) Error() string {
+	return "SCIM token not found"
+}
+
+// SCIMUserNotFoundError represents when a provisioned user is not found.
+type SCIMUserNotFoundError struct{}
+
+func (e SCIMUserNotFoundError) Error() string {
+	return "User not found"
+}
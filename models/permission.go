@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/delivc/team/storage"
@@ -13,16 +14,40 @@ import (
 
 // Permission exports `Id` and `Match`
 type Permission struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	CreatedAt time.Time `json:"-" db:"created_at"`
-	UpdatedAt time.Time `json:"-" db:"updated_at"`
-	Roles     []Role    `json:"-" many_to_many:"roles_permissions"`
+	ID           uuid.UUID  `json:"id" db:"id"`
+	Name         string     `json:"name" db:"name"`
+	Description  string     `json:"description,omitempty" db:"description"`
+	Namespace    string     `json:"namespace,omitempty" db:"namespace"`
+	Category     string     `json:"category,omitempty" db:"category"`
+	DeprecatedAt *time.Time `json:"deprecated_at,omitempty" db:"deprecated_at"`
+	RetiredAt    *time.Time `json:"-" db:"retired_at"`
+	CreatedAt    time.Time  `json:"-" db:"created_at"`
+	UpdatedAt    time.Time  `json:"-" db:"updated_at"`
+	Roles        []Role     `json:"-" many_to_many:"roles_permissions"`
+	// Conditions of the permission within a role
+	Conditions *Conditions `json:"conditions,omitempty" db:"-"`
+}
+
+// PermissionDefinition describes a permission a service registers
+type PermissionDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Namespace   string `json:"namespace"`
+	Category    string `json:"category"`
 }
 
 // Permissions is a list of Permissions
 type Permissions []Permission
 
+// Names returns the names of the permissions
+func (p Permissions) Names() []string {
+	names := []string{}
+	for _, permission := range p {
+		names = append(names, permission.Name)
+	}
+	return names
+}
+
 // TableName returns the given tablename of the model
 func (Permission) TableName() string {
 	tableName := "permissions"
@@ -34,6 +59,19 @@ func (Permission) TableName() string {
 	return tableName
 }
 
+// WildcardPermission grants every permission
+const WildcardPermission = "*"
+
+// PermissionMatches checks if a granted permission covers the requested one.
+// Grants ending with `*` match every permission with the same prefix,
+// so `spaces-*` covers `spaces-edit-content` and `*` covers everything
+func PermissionMatches(grant, permission string) bool {
+	if strings.HasSuffix(grant, WildcardPermission) {
+		return strings.HasPrefix(permission, strings.TrimSuffix(grant, WildcardPermission))
+	}
+	return grant == permission
+}
+
 // NewPermission creates a new Permission
 func NewPermission(name string) (*Permission, error) {
 	id, err := uuid.NewV4()
@@ -50,7 +88,7 @@ func NewPermission(name string) (*Permission, error) {
 
 // AllPermissions returns all Permissions from the Database
 func AllPermissions(tx *storage.Connection) (permissions []Permission, err error) {
-	err = tx.All(&permissions)
+	err = tx.Q().Where("retired_at IS NULL").All(&permissions)
 	if err != nil {
 		return nil, errors.Wrap(err, "error finding permissions")
 	}
@@ -65,7 +103,7 @@ func FindPermissionsByName(tx *storage.Connection, request []string) ([]Permissi
 	}
 	q := tx.Q()
 
-	q.Where("name IN (?)", request)
+	q.Where("name IN (?) AND retired_at IS NULL", request)
 
 	if err := q.All(&permissions); err != nil {
 		if errors.Cause(err) == sql.ErrNoRows {
@@ -81,11 +119,47 @@ func FindPermissionsByName(tx *storage.Connection, request []string) ([]Permissi
 	return permissions, nil
 }
 
+// UnknownPermissionNames returns the names which belong to no registered permission,
+// FindPermissionsByName silently skips them
+func UnknownPermissionNames(tx *storage.Connection, names []string) ([]string, error) {
+	unknown := []string{}
+	if len(names) == 0 {
+		return unknown, nil
+	}
+	permissions, err := FindPermissionsByName(tx, names)
+	if err != nil {
+		return nil, err
+	}
+	registered := map[string]bool{}
+	for _, permission := range permissions {
+		registered[permission.Name] = true
+	}
+	for _, name := range names {
+		if !registered[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown, nil
+}
+
 // FindPermissions returns a list of Permissions if any
-func FindPermissions(tx *storage.Connection, pageParams *Pagination, sortParams *SortParams) ([]*Permission, error) {
+// an empty ns returns the Permissions of all namespaces
+func FindPermissions(tx *storage.Connection, ns string, pageParams *Pagination, sortParams *SortParams) ([]*Permission, error) {
 	permissions := []*Permission{}
 
-	q := tx.Q()
+	q := tx.Q().Where("retired_at IS NULL")
+	if ns != "" {
+		q = q.Where("namespace = ?", ns)
+	}
+
+	if pageParams != nil && pageParams.Keyset {
+		if err := pageParams.applyCursor(q, "id").All(&permissions); err != nil {
+			return nil, err
+		}
+		return permissions[:pageParams.trim(len(permissions), func(i int) Cursor {
+			return Cursor{CreatedAt: permissions[i].CreatedAt, ID: permissions[i].ID}
+		})], nil
+	}
 
 	if sortParams != nil && len(sortParams.Fields) > 0 {
 		for _, field := range sortParams.Fields {
@@ -103,3 +177,110 @@ func FindPermissions(tx *storage.Connection, pageParams *Pagination, sortParams
 
 	return permissions, err
 }
+
+// FindPermissionByName returns a single permission, retired ones included
+func FindPermissionByName(tx *storage.Connection, name string) (*Permission, error) {
+	obj := &Permission{}
+	if err := tx.Q().Where("name = ?", name).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, PermissionNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding permission")
+	}
+	return obj, nil
+}
+
+// RegisterPermissions creates or updates the given permissions.
+// Registering is idempotent, a retired permission gets revived
+func RegisterPermissions(tx *storage.Connection, definitions []PermissionDefinition) ([]Permission, error) {
+	permissions := []Permission{}
+	for _, definition := range definitions {
+		if definition.Name == "" {
+			return nil, errors.New("Error: invalid permission name")
+		}
+
+		permission, err := FindPermissionByName(tx, definition.Name)
+		if err != nil {
+			if _, ok := err.(PermissionNotFoundError); !ok {
+				return nil, err
+			}
+			if permission, err = createPermission(tx, definition); err != nil {
+				return nil, err
+			}
+			permissions = append(permissions, *permission)
+			continue
+		}
+
+		permission.Description = definition.Description
+		permission.Namespace = definition.Namespace
+		permission.Category = definition.Category
+		permission.RetiredAt = nil
+		if err = tx.UpdateOnly(permission, "description", "namespace", "category", "retired_at", "updated_at"); err != nil {
+			return nil, errors.Wrap(err, "error updating permission")
+		}
+		permissions = append(permissions, *permission)
+	}
+	return permissions, nil
+}
+
+// SeedPermissions creates the given permissions which do not exist yet.
+// Existing ones stay as they are, so retired permissions are not revived
+func SeedPermissions(tx *storage.Connection, definitions []PermissionDefinition) ([]Permission, error) {
+	permissions := []Permission{}
+	for _, definition := range definitions {
+		_, err := FindPermissionByName(tx, definition.Name)
+		if err == nil {
+			continue
+		}
+		if _, ok := err.(PermissionNotFoundError); !ok {
+			return nil, err
+		}
+		permission, err := createPermission(tx, definition)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, *permission)
+	}
+	return permissions, nil
+}
+
+func createPermission(tx *storage.Connection, definition PermissionDefinition) (*Permission, error) {
+	permission, err := NewPermission(definition.Name)
+	if err != nil {
+		return nil, err
+	}
+	permission.Description = definition.Description
+	permission.Namespace = definition.Namespace
+	permission.Category = definition.Category
+	if err = tx.Create(permission); err != nil {
+		return nil, errors.Wrap(err, "error creating permission")
+	}
+	return permission, nil
+}
+
+// Deprecate marks the permission as deprecated,
+// it still works but should not be granted anymore
+func (p *Permission) Deprecate(tx *storage.Connection) error {
+	if p.DeprecatedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	p.DeprecatedAt = &now
+	return tx.UpdateOnly(p, "deprecated_at", "updated_at")
+}
+
+// Retire removes the permission from all roles and hides it.
+// The row is kept, so the name can be registered again later
+func (p *Permission) Retire(tx *storage.Connection) error {
+	tableName := RolePermission{}.TableName()
+	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE permission_id = ?", p.ID).Exec(); err != nil {
+		return errors.Wrap(err, "error detaching permission from roles")
+	}
+
+	now := time.Now()
+	if p.DeprecatedAt == nil {
+		p.DeprecatedAt = &now
+	}
+	p.RetiredAt = &now
+	return tx.UpdateOnly(p, "deprecated_at", "retired_at", "updated_at")
+}
@@ -0,0 +1,30 @@
+package models
+
+// DefaultPermissions are created by every migration unless they exist,
+// retired or deprecated ones stay that way
+var DefaultPermissions = []PermissionDefinition{
+	{Name: "*", Description: "Every permission, including the ones registered in the future", Namespace: "team", Category: "wildcards"},
+	{Name: "spaces-*", Description: "Every permission of spaces", Namespace: "spaces", Category: "wildcards"},
+	{Name: "account-*", Description: "Every permission of the account", Namespace: "team", Category: "wildcards"},
+	{Name: "spaces-create", Description: "Create new spaces", Namespace: "spaces", Category: "spaces"},
+	{Name: "spaces-edit", Description: "Edit the settings of spaces", Namespace: "spaces", Category: "spaces"},
+	{Name: "spaces-delete", Description: "Delete spaces", Namespace: "spaces", Category: "spaces"},
+	{Name: "spaces-read-apikeys", Description: "Read the api keys of spaces", Namespace: "spaces", Category: "apikeys"},
+	{Name: "spaces-create-apikeys", Description: "Create api keys for spaces", Namespace: "spaces", Category: "apikeys"},
+	{Name: "spaces-destroy-apikeys", Description: "Destroy api keys of spaces", Namespace: "spaces", Category: "apikeys"},
+	{Name: "spaces-create-models", Description: "Create content models", Namespace: "spaces", Category: "models"},
+	{Name: "spaces-edit-models", Description: "Edit content models", Namespace: "spaces", Category: "models"},
+	{Name: "spaces-destroy-models", Description: "Destroy content models", Namespace: "spaces", Category: "models"},
+	{Name: "spaces-create-content", Description: "Create content", Namespace: "spaces", Category: "content"},
+	{Name: "spaces-edit-content", Description: "Edit content", Namespace: "spaces", Category: "content"},
+	{Name: "spaces-destroy-content", Description: "Destroy content", Namespace: "spaces", Category: "content"},
+	{Name: "spaces-create-assets", Description: "Upload assets", Namespace: "spaces", Category: "assets"},
+	{Name: "spaces-destroy-assets", Description: "Destroy assets", Namespace: "spaces", Category: "assets"},
+	{Name: "account-edit", Description: "Edit the account and its billing details", Namespace: "team", Category: "account"},
+	{Name: "account-destroy", Description: "Destroy the account", Namespace: "team", Category: "account"},
+	{Name: "account-users-invite", Description: "Invite users to the account", Namespace: "team", Category: "users"},
+	{Name: "account-users-remove", Description: "Remove users from the account", Namespace: "team", Category: "users"},
+	{Name: "account-role-create", Description: "Create roles", Namespace: "team", Category: "roles"},
+	{Name: "account-role-update", Description: "Update roles and role assignments", Namespace: "team", Category: "roles"},
+	{Name: "account-role-destroy", Description: "Destroy roles", Namespace: "team", Category: "roles"},
+}
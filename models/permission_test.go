@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionMatches(t *testing.T) {
+	cases := []struct {
+		grant      string
+		permission string
+		expected   bool
+	}{
+		{"spaces-edit", "spaces-edit", true},
+		{"spaces-edit", "spaces-edit-content", false},
+		{"spaces-*", "spaces-edit-content", true},
+		{"spaces-*", "account-edit", false},
+		{"spaces-edit-*", "spaces-edit-content", true},
+		{"spaces-edit-*", "spaces-edit", false},
+		{"*", "account-role-destroy", true},
+		{"*", "anything", true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, PermissionMatches(c.grant, c.permission), "%s should match %s: %v", c.grant, c.permission, c.expected)
+	}
+}
@@ -4,29 +4,46 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/delivc/team/conf"
 	"github.com/delivc/team/storage"
 	"github.com/delivc/team/storage/namespace"
 	"github.com/gofrs/uuid"
 	"github.com/pkg/errors"
 )
 
+// AdminTemplate is the role template which is granted everything,
+// its members take over accounts whose last owner is removed
+const AdminTemplate = "Admin"
+
 // Role reflects a given Role within an Account
 type Role struct {
 	AccountID   uuid.UUID   `json:"-" db:"account_id"`
 	ID          uuid.UUID   `json:"id" db:"id"`
 	Name        string      `json:"name" db:"name"`
+	Template    string      `json:"template,omitempty" db:"template"`
+	System      bool        `json:"system" db:"is_system"`
 	CreatedAt   time.Time   `json:"createdAt" db:"created_at"`
 	UpdatedAt   time.Time   `json:"updatedAt" db:"updated_at"`
 	Permissions Permissions `json:"permissions,omitempty" many_to_many:"roles_permissions"`
+	// DeniedPermissions override grants of all other roles of a member
+	DeniedPermissions Permissions `json:"denied_permissions,omitempty" db:"-"`
 }
 
+// Effects of a permission within a role
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
 // RolePermission relationship between roles and permissions
 type RolePermission struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	RoleID       uuid.UUID `db:"role_id"`
-	PermissionID uuid.UUID `db:"permission_id"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	ID           uuid.UUID   `json:"id" db:"id"`
+	RoleID       uuid.UUID   `db:"role_id"`
+	PermissionID uuid.UUID   `db:"permission_id"`
+	Effect       string      `json:"effect" db:"effect"`
+	Conditions   *Conditions `json:"conditions,omitempty" db:"raw_conditions"`
+	CreatedAt    time.Time   `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time   `json:"updatedAt" db:"updated_at"`
 }
 
 // TableName returns the given tablename of the model
@@ -65,44 +82,113 @@ func (r *Role) UpdatePermissions(tx *storage.Connection, perms []string) error {
 	if perms == nil {
 		return errors.New("Error: invalid Permissions")
 	}
+	return r.updateGrants(tx, EffectAllow, perms)
+}
+
+// UpdateDeniedPermissions syncs the denied permissions of role,
+// an empty list removes all deny rules
+func (r *Role) UpdateDeniedPermissions(tx *storage.Connection, perms []string) error {
+	return r.updateGrants(tx, EffectDeny, perms)
+}
+
+// updateGrants replaces the permissions of one effect and reloads the permissions of the role
+func (r *Role) updateGrants(tx *storage.Connection, effect string, perms []string) error {
 	var err error
-	var permissions []Permission
+	permissions := []Permission{}
 
-	if permissions, err = FindPermissionsByName(tx, perms); err != nil {
-		return errors.Wrap(err, "Error finding Permissions")
+	if len(perms) > 0 {
+		if permissions, err = FindPermissionsByName(tx, perms); err != nil {
+			return errors.Wrap(err, "Error finding Permissions")
+		}
 	}
 
-	if err = detachAllPermissions(tx, r.ID); err != nil {
+	current := []RolePermission{}
+	if err = tx.Q().Where("role_id = ?", r.ID).All(&current); err != nil {
+		return errors.Wrap(err, "error finding role permissions")
+	}
+	rows, err := grantRows(r.ID, current, effect, permissions)
+	if err != nil {
 		return err
 	}
 
-	for _, permission := range permissions {
-		if err = attachPermission(tx, r.ID, permission.ID); err != nil {
-			return err
+	tableName := RolePermission{}.TableName()
+	if err = tx.RawQuery("DELETE FROM "+tableName+" WHERE role_id = ?", r.ID).Exec(); err != nil {
+		return errors.Wrap(err, "Error detaching permissions")
+	}
+	for i := range rows {
+		if err = tx.Create(&rows[i]); err != nil {
+			return errors.Wrap(err, "Error attaching permission")
 		}
 	}
-	r.Permissions = permissions
-
-	return nil
+	return loadPermissions(tx, r)
 }
 
-func attachPermission(tx *storage.Connection, roleID uuid.UUID, permissionID uuid.UUID) error {
-	id, err := uuid.NewV4()
-	if err != nil {
-		return errors.Wrap(err, "Error generating unique id")
+// grantRows returns the rows of a role after the permissions of one effect are replaced.
+// A permission has a single effect within a role, rows of the other effect for the
+// same permission are dropped so a permission can move from deny to grant and back.
+// Permissions which keep their effect keep their conditions
+func grantRows(roleID uuid.UUID, current []RolePermission, effect string, permissions []Permission) ([]RolePermission, error) {
+	replaced := map[uuid.UUID]bool{}
+	for _, permission := range permissions {
+		replaced[permission.ID] = true
 	}
-	p := RolePermission{
-		ID:           id,
-		RoleID:       roleID,
-		PermissionID: permissionID,
+
+	rows := []RolePermission{}
+	kept := map[uuid.UUID]bool{}
+	for _, row := range current {
+		if row.Effect == effect && replaced[row.PermissionID] {
+			kept[row.PermissionID] = true
+			rows = append(rows, row)
+		} else if row.Effect != effect && !replaced[row.PermissionID] {
+			rows = append(rows, row)
+		}
+	}
+	for _, permission := range permissions {
+		if kept[permission.ID] {
+			continue
+		}
+		id, err := uuid.NewV4()
+		if err != nil {
+			return nil, errors.Wrap(err, "Error generating unique id")
+		}
+		rows = append(rows, RolePermission{ID: id, RoleID: roleID, PermissionID: permission.ID, Effect: effect})
 	}
-	return tx.Create(&p)
+	return rows, nil
 }
 
-func detachAllPermissions(tx *storage.Connection, roleID uuid.UUID) error {
+// SetConditions restricts when permissions of the role apply,
+// a nil entry removes the conditions of the permission
+func (r *Role) SetConditions(tx *storage.Connection, conditions map[string]*Conditions) error {
 	tableName := RolePermission{}.TableName()
 
-	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE role_id = ?", roleID).Exec(); err != nil {
+	for name, condition := range conditions {
+		var permission *Permission
+		for i := range r.Permissions {
+			if r.Permissions[i].Name == name {
+				permission = &r.Permissions[i]
+			}
+		}
+		for i := range r.DeniedPermissions {
+			if r.DeniedPermissions[i].Name == name {
+				permission = &r.DeniedPermissions[i]
+			}
+		}
+		if permission == nil {
+			return errors.Errorf("Error: role has no permission %v", name)
+		}
+
+		if err := tx.RawQuery("UPDATE "+tableName+" SET raw_conditions = ?, updated_at = ? WHERE role_id = ? AND permission_id = ?", condition, time.Now(), r.ID, permission.ID).Exec(); err != nil {
+			return errors.Wrap(err, "Error updating conditions")
+		}
+		permission.Conditions = condition
+	}
+	return nil
+}
+
+func detachPermissions(tx *storage.Connection, roleID uuid.UUID, effect string) error {
+	tableName := RolePermission{}.TableName()
+
+	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE role_id = ? AND effect = ?", roleID, effect).Exec(); err != nil {
 		return err
 	}
 	return nil
@@ -124,6 +210,62 @@ func NewRole(accountID uuid.UUID, name string) (*Role, error) {
 	return role, nil
 }
 
+// SyncRoleTemplates creates the missing template roles of an account
+// and resets the permissions of existing ones to their template.
+// Roles are returned in the order of the templates
+func SyncRoleTemplates(tx *storage.Connection, accountID uuid.UUID, templates []conf.RoleTemplate) ([]*Role, error) {
+	roles := []*Role{}
+	for _, template := range templates {
+		existing, err := findRoles(tx, "account_id = ? and template = ?", accountID, template.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(existing) > 0 {
+			role := existing[0]
+			if err = role.syncTemplatePermissions(tx, template.Permissions); err != nil {
+				return nil, err
+			}
+			if !role.System {
+				role.System = true
+				if err = tx.UpdateOnly(role, "is_system", "updated_at"); err != nil {
+					return nil, errors.Wrap(err, "Error protecting template role")
+				}
+			}
+			roles = append(roles, role)
+			continue
+		}
+
+		role, err := NewRole(accountID, template.Name)
+		if err != nil {
+			return nil, err
+		}
+		role.Template = template.Name
+		role.System = true
+		if len(template.Permissions) > 0 {
+			if role.Permissions, err = FindPermissionsByName(tx, template.Permissions); err != nil {
+				return nil, errors.Wrap(err, "Error finding Permissions")
+			}
+		}
+		if err = tx.Create(role); err != nil {
+			return nil, errors.Wrap(err, "Error saving template role")
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *Role) syncTemplatePermissions(tx *storage.Connection, perms []string) error {
+	if len(perms) > 0 {
+		return r.UpdatePermissions(tx, perms)
+	}
+	if err := detachPermissions(tx, r.ID, EffectAllow); err != nil {
+		return err
+	}
+	r.Permissions = Permissions{}
+	return nil
+}
+
 // AttachRole sets the Role of the user within the Account Space
 // The relationship between the User and Account MUST exists when calling this
 func AttachRole(tx *storage.Connection, accountID uuid.UUID, userID uuid.UUID, roleID uuid.UUID) error {
@@ -132,36 +274,181 @@ func AttachRole(tx *storage.Connection, accountID uuid.UUID, userID uuid.UUID, r
 
 func findRole(tx *storage.Connection, query string, args ...interface{}) (*Role, error) {
 	obj := &Role{}
-	if err := tx.Q().Eager().Where(query, args...).First(obj); err != nil {
+	if err := tx.Q().Where(query, args...).First(obj); err != nil {
 		if errors.Cause(err) == sql.ErrNoRows {
 			return nil, RoleNotFoundError{}
 		}
 		return nil, errors.Wrap(err, "error finding role")
 	}
+	if err := loadPermissions(tx, obj); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
 func findRoles(tx *storage.Connection, query string, args ...interface{}) ([]*Role, error) {
 	obj := []*Role{}
-	if err := tx.Q().Eager().Where(query, args...).All(&obj); err != nil {
+	if err := tx.Q().Where(query, args...).All(&obj); err != nil {
 		if errors.Cause(err) == sql.ErrNoRows {
 			return nil, RoleNotFoundError{}
 		}
 		return nil, errors.Wrap(err, "error finding roles")
 	}
+	if err := loadPermissions(tx, obj...); err != nil {
+		return nil, err
+	}
 	return obj, nil
 }
 
+// loadPermissions fills the granted and denied permissions of roles,
+// eager loading can not tell them apart
+func loadPermissions(tx *storage.Connection, roles ...*Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	roleIDs := []uuid.UUID{}
+	for _, role := range roles {
+		role.Permissions = Permissions{}
+		role.DeniedPermissions = Permissions{}
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	rolePermissions := []RolePermission{}
+	if err := tx.Q().Where("role_id IN (?)", roleIDs).All(&rolePermissions); err != nil {
+		return errors.Wrap(err, "error finding role permissions")
+	}
+	if len(rolePermissions) == 0 {
+		return nil
+	}
+
+	permissionIDs := []uuid.UUID{}
+	for _, rp := range rolePermissions {
+		permissionIDs = append(permissionIDs, rp.PermissionID)
+	}
+	permissions := []Permission{}
+	if err := tx.Q().Where("id IN (?)", permissionIDs).All(&permissions); err != nil {
+		return errors.Wrap(err, "error finding permissions")
+	}
+	byID := map[uuid.UUID]Permission{}
+	for _, permission := range permissions {
+		byID[permission.ID] = permission
+	}
+
+	for _, role := range roles {
+		for _, rp := range rolePermissions {
+			permission, ok := byID[rp.PermissionID]
+			if rp.RoleID != role.ID || !ok {
+				continue
+			}
+			permission.Conditions = rp.Conditions
+			if rp.Effect == EffectDeny {
+				role.DeniedPermissions = append(role.DeniedPermissions, permission)
+			} else {
+				role.Permissions = append(role.Permissions, permission)
+			}
+		}
+	}
+	return nil
+}
+
 // FindRolesByAccount returns a list of roles by account or error
 func FindRolesByAccount(tx *storage.Connection, id uuid.UUID) ([]*Role, error) {
 	return findRoles(tx, "account_id = ?", id)
 }
 
+// FindRolesPage returns one page of the roles of an account
+func FindRolesPage(tx *storage.Connection, accountID uuid.UUID, pageParams *Pagination) ([]*Role, error) {
+	roles := []*Role{}
+	if err := pageParams.applyCursor(tx.Q().Where("account_id = ?", accountID), "id").All(&roles); err != nil {
+		return nil, errors.Wrap(err, "error finding roles")
+	}
+	roles = roles[:pageParams.trim(len(roles), func(i int) Cursor {
+		return Cursor{CreatedAt: roles[i].CreatedAt, ID: roles[i].ID}
+	})]
+	if err := loadPermissions(tx, roles...); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
 // FindRoleByAccountAndID returns roles by account and id
 func FindRoleByAccountAndID(tx *storage.Connection, accountID uuid.UUID, roleID uuid.UUID) (*Role, error) {
 	return findRole(tx, "account_id = ? and id = ?", accountID, roleID)
 }
 
+// RoleNameExists checks if another role of the account has the name
+func RoleNameExists(tx *storage.Connection, accountID uuid.UUID, name string, exceptID uuid.UUID) (bool, error) {
+	exists, err := tx.Q().Where("account_id = ? and name = ? and id <> ?", accountID, name, exceptID).Exists(&Role{})
+	if err != nil {
+		return false, errors.Wrap(err, "error finding roles")
+	}
+	return exists, nil
+}
+
+// roleReferences are the models which point to a role and simply move
+// to another role when it is deleted
+var roleReferences = []struct {
+	model interface{ TableName() string }
+	name  string
+}{
+	{AccountUser{}, "members"},
+	{AccountDomain{}, "account domains"},
+	{SCIMUser{}, "provisioned users"},
+	{SCIMToken{}, "SCIM tokens"},
+}
+
+// CountRoleMembers returns how many members, resource assignments, domains
+// and provisioning settings use a role
+func CountRoleMembers(tx *storage.Connection, roleID uuid.UUID) (int, error) {
+	total := 0
+	for _, ref := range roleReferences {
+		count, err := tx.Q().Where("role_id = ?", roleID).Count(ref.model)
+		if err != nil {
+			return 0, errors.Wrap(err, "error counting "+ref.name)
+		}
+		total += count
+	}
+	assignments, err := tx.Q().Where("role_id = ?", roleID).Count(&RoleAssignment{})
+	if err != nil {
+		return 0, errors.Wrap(err, "error counting role assignments")
+	}
+	return total + assignments, nil
+}
+
+// ReassignRole moves everything which uses a role to another role
+func ReassignRole(tx *storage.Connection, accountID uuid.UUID, fromRoleID uuid.UUID, toRoleID uuid.UUID) error {
+	for _, ref := range roleReferences {
+		if err := tx.RawQuery("UPDATE "+ref.model.TableName()+" SET role_id = ? WHERE account_id = ? AND role_id = ?", toRoleID, accountID, fromRoleID).Exec(); err != nil {
+			return errors.Wrap(err, "error reassigning "+ref.name)
+		}
+	}
+
+	assignments, err := findRoleAssignments(tx, "account_id = ? and role_id = ?", accountID, fromRoleID)
+	if err != nil {
+		return err
+	}
+	for _, assignment := range assignments {
+		// the member may already have the new role for this resource
+		existing, err := findRoleAssignments(tx, "account_id = ? and user_id = ? and role_id = ? and resource_type = ? and resource_id = ?",
+			accountID, assignment.UserID, toRoleID, assignment.ResourceType, assignment.ResourceID)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			if err = DeleteRoleAssignment(tx, assignment.ID); err != nil {
+				return errors.Wrap(err, "error removing duplicate role assignment")
+			}
+			continue
+		}
+		assignment.RoleID = toRoleID
+		if err = tx.UpdateOnly(assignment, "role_id", "updated_at"); err != nil {
+			return errors.Wrap(err, "error reassigning role assignment")
+		}
+	}
+	return nil
+}
+
 // DeleteRole destroys a role in storage
 func DeleteRole(tx *storage.Connection, roleID uuid.UUID) error {
 	return tx.Destroy(&Role{ID: roleID})
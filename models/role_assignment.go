@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// Resource identifies a single entity of another service,
+// eg. a space, written as `space:abc`
+type Resource struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// ParseResource parses a resource in the `type:id` notation
+func ParseResource(value string) (*Resource, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.Errorf("invalid resource '%v', expected 'type:id'", value)
+	}
+	return &Resource{Type: parts[0], ID: parts[1]}, nil
+}
+
+// String returns the resource in the `type:id` notation
+func (r Resource) String() string {
+	return r.Type + ":" + r.ID
+}
+
+// RoleAssignment grants a Role to a member of an Account
+// for a single Resource only
+type RoleAssignment struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	AccountID    uuid.UUID  `json:"-" db:"account_id"`
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	RoleID       uuid.UUID  `json:"role_id" db:"role_id"`
+	ResourceType string     `json:"resource_type" db:"resource_type"`
+	ResourceID   string     `json:"resource_id" db:"resource_id"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// TableName returns the given tablename of the model
+func (RoleAssignment) TableName() string {
+	tableName := "role_assignments"
+
+	if namespace.GetNamespace() != "" {
+		return namespace.GetNamespace() + "_" + tableName
+	}
+
+	return tableName
+}
+
+// Resource returns the scope of the assignment
+func (ra *RoleAssignment) Resource() Resource {
+	return Resource{Type: ra.ResourceType, ID: ra.ResourceID}
+}
+
+// NewRoleAssignment creates a new RoleAssignment
+// does not create!!! the assignment in the database
+func NewRoleAssignment(accountID, userID, roleID uuid.UUID, resource Resource) (*RoleAssignment, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error generating unique id")
+	}
+
+	assignment := &RoleAssignment{
+		ID:           id,
+		AccountID:    accountID,
+		UserID:       userID,
+		RoleID:       roleID,
+		ResourceType: resource.Type,
+		ResourceID:   resource.ID,
+	}
+	return assignment, nil
+}
+
+func findRoleAssignments(tx *storage.Connection, query string, args ...interface{}) ([]*RoleAssignment, error) {
+	obj := []*RoleAssignment{}
+	if err := tx.Q().Where(query, args...).All(&obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return obj, nil
+		}
+		return nil, errors.Wrap(err, "error finding role assignments")
+	}
+	return obj, nil
+}
+
+// FindRoleAssignmentsByAccount returns all active resource scoped assignments of an account
+func FindRoleAssignmentsByAccount(tx *storage.Connection, accountID uuid.UUID) ([]*RoleAssignment, error) {
+	return findRoleAssignments(tx, "account_id = ? and (expires_at IS NULL or expires_at > ?)", accountID, time.Now())
+}
+
+// FindRoleAssignmentsForResource returns the active assignments of a user
+// within an account for the given resource
+func FindRoleAssignmentsForResource(tx *storage.Connection, accountID, userID uuid.UUID, resource Resource) ([]*RoleAssignment, error) {
+	return findRoleAssignments(tx, "account_id = ? and user_id = ? and resource_type = ? and resource_id = ? and (expires_at IS NULL or expires_at > ?)",
+		accountID, userID, resource.Type, resource.ID, time.Now())
+}
+
+// FindRoleAssignmentsOfUser returns the active assignments of a user within an account
+func FindRoleAssignmentsOfUser(tx *storage.Connection, accountID, userID uuid.UUID) ([]*RoleAssignment, error) {
+	return findRoleAssignments(tx, "account_id = ? and user_id = ? and (expires_at IS NULL or expires_at > ?)", accountID, userID, time.Now())
+}
+
+// FindRoleAssignmentByAccountAndID returns a single assignment of an account
+func FindRoleAssignmentByAccountAndID(tx *storage.Connection, accountID, id uuid.UUID) (*RoleAssignment, error) {
+	obj := &RoleAssignment{}
+	if err := tx.Q().Where("account_id = ? and id = ?", accountID, id).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, RoleAssignmentNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding role assignment")
+	}
+	return obj, nil
+}
+
+// DeleteRoleAssignment removes an assignment from storage
+func DeleteRoleAssignment(tx *storage.Connection, id uuid.UUID) error {
+	return tx.Destroy(&RoleAssignment{ID: id})
+}
+
+// DeleteRoleAssignmentsOfUser removes all assignments of a user within an account
+func DeleteRoleAssignmentsOfUser(tx *storage.Connection, accountID, userID uuid.UUID) error {
+	tableName := RoleAssignment{}.TableName()
+	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE account_id = ? AND user_id = ?", accountID, userID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting role assignments")
+	}
+	return nil
+}
+
+// DeleteExpiredRoleAssignments removes all assignments which have run out
+// and returns them
+func DeleteExpiredRoleAssignments(tx *storage.Connection, now time.Time) ([]*RoleAssignment, error) {
+	expired, err := findRoleAssignments(tx, "expires_at IS NOT NULL and expires_at <= ?", now)
+	if err != nil {
+		return nil, err
+	}
+	for _, assignment := range expired {
+		if err := DeleteRoleAssignment(tx, assignment.ID); err != nil {
+			return nil, errors.Wrap(err, "error deleting expired role assignment")
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// effects maps the permissions of role rows to their effect
+func effects(t *testing.T, rows []RolePermission) map[uuid.UUID]string {
+	result := map[uuid.UUID]string{}
+	for _, row := range rows {
+		_, exists := result[row.PermissionID]
+		require.False(t, exists, "permission %v is attached twice", row.PermissionID)
+		result[row.PermissionID] = row.Effect
+	}
+	return result
+}
+
+func TestGrantRowsMovesDeniedPermissionToGrant(t *testing.T) {
+	roleID := uuid.Must(uuid.NewV4())
+	x := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-delete"}
+	current := []RolePermission{{ID: uuid.Must(uuid.NewV4()), RoleID: roleID, PermissionID: x.ID, Effect: EffectDeny}}
+
+	// {"permissions": ["spaces-delete"], "deny": []}
+	rows, err := grantRows(roleID, current, EffectAllow, []Permission{x})
+	require.NoError(t, err)
+	rows, err = grantRows(roleID, rows, EffectDeny, []Permission{})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[uuid.UUID]string{x.ID: EffectAllow}, effects(t, rows))
+}
+
+func TestGrantRowsMovesGrantedPermissionToDeny(t *testing.T) {
+	roleID := uuid.Must(uuid.NewV4())
+	x := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-delete"}
+	y := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-edit"}
+	current := []RolePermission{
+		{ID: uuid.Must(uuid.NewV4()), RoleID: roleID, PermissionID: x.ID, Effect: EffectAllow},
+		{ID: uuid.Must(uuid.NewV4()), RoleID: roleID, PermissionID: y.ID, Effect: EffectAllow},
+	}
+
+	// {"permissions": ["spaces-edit"], "deny": ["spaces-delete"]}
+	rows, err := grantRows(roleID, current, EffectAllow, []Permission{y})
+	require.NoError(t, err)
+	rows, err = grantRows(roleID, rows, EffectDeny, []Permission{x})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[uuid.UUID]string{x.ID: EffectDeny, y.ID: EffectAllow}, effects(t, rows))
+}
+
+func TestGrantRowsPatchGrantingDeniedPermission(t *testing.T) {
+	roleID := uuid.Must(uuid.NewV4())
+	x := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-delete"}
+	y := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-edit"}
+	current := []RolePermission{
+		{ID: uuid.Must(uuid.NewV4()), RoleID: roleID, PermissionID: y.ID, Effect: EffectAllow},
+		{ID: uuid.Must(uuid.NewV4()), RoleID: roleID, PermissionID: x.ID, Effect: EffectDeny},
+	}
+
+	// PATCH {"permissions": ["spaces-edit", "spaces-delete"], "deny": []}
+	// updates the granted permissions before the denied ones
+	rows, err := grantRows(roleID, current, EffectAllow, []Permission{y, x})
+	require.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]string{x.ID: EffectAllow, y.ID: EffectAllow}, effects(t, rows))
+
+	rows, err = grantRows(roleID, rows, EffectDeny, []Permission{})
+	require.NoError(t, err)
+	assert.Equal(t, map[uuid.UUID]string{x.ID: EffectAllow, y.ID: EffectAllow}, effects(t, rows))
+}
+
+func TestGrantRowsKeepsConditions(t *testing.T) {
+	roleID := uuid.Must(uuid.NewV4())
+	x := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-delete"}
+	y := Permission{ID: uuid.Must(uuid.NewV4()), Name: "spaces-edit"}
+	conditions := &Conditions{Audiences: []string{"app"}}
+	current := []RolePermission{
+		{ID: uuid.Must(uuid.NewV4()), RoleID: roleID, PermissionID: x.ID, Effect: EffectAllow, Conditions: conditions},
+	}
+
+	// PUT {"permissions": ["spaces-delete", "spaces-edit"]} without conditions
+	rows, err := grantRows(roleID, current, EffectAllow, []Permission{x, y})
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	for _, row := range rows {
+		if row.PermissionID == x.ID {
+			assert.Equal(t, current[0].ID, row.ID)
+			assert.Equal(t, conditions, row.Conditions)
+		} else {
+			assert.Nil(t, row.Conditions)
+		}
+	}
+
+	// moving to deny starts without conditions
+	rows, err = grantRows(roleID, rows, EffectDeny, []Permission{x})
+	require.NoError(t, err)
+	for _, row := range rows {
+		if row.PermissionID == x.ID {
+			assert.Equal(t, EffectDeny, row.Effect)
+			assert.Nil(t, row.Conditions)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// SCIMToken authenticates the SCIM provisioning of an account,
+// only the hash of the token is stored
+type SCIMToken struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	AccountID uuid.UUID `json:"-" db:"account_id"`
+	RoleID    uuid.UUID `json:"role_id" db:"role_id"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	CreatedBy uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TableName returns the given tablename of the model
+func (SCIMToken) TableName() string {
+	tableName := "scim_tokens"
+
+	if namespace.GetNamespace() != "" {
+		return namespace.GetNamespace() + "_" + tableName
+	}
+
+	return tableName
+}
+
+// HashSCIMToken returns the stored representation of a token
+func HashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSCIMToken creates a new SCIMToken and returns it with the plain token,
+// roleID is the role of provisioned users which are in no group
+// does not create!!! the token in the database
+func NewSCIMToken(accountID, roleID, createdBy uuid.UUID) (*SCIMToken, string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "Error generating unique id")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", errors.Wrap(err, "Error generating SCIM token")
+	}
+	token := hex.EncodeToString(secret)
+
+	scimToken := &SCIMToken{
+		ID:        id,
+		AccountID: accountID,
+		RoleID:    roleID,
+		TokenHash: HashSCIMToken(token),
+		CreatedBy: createdBy,
+	}
+	return scimToken, token, nil
+}
+
+// FindSCIMTokenByToken returns the SCIM token matching the plain token
+func FindSCIMTokenByToken(tx *storage.Connection, token string) (*SCIMToken, error) {
+	obj := &SCIMToken{}
+	if err := tx.Q().Where("token_hash = ?", HashSCIMToken(token)).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SCIMTokenNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding SCIM token")
+	}
+	return obj, nil
+}
+
+// FindSCIMTokenByAccount returns the SCIM token of an account
+func FindSCIMTokenByAccount(tx *storage.Connection, accountID uuid.UUID) (*SCIMToken, error) {
+	obj := &SCIMToken{}
+	if err := tx.Q().Where("account_id = ?", accountID).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SCIMTokenNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding SCIM token")
+	}
+	return obj, nil
+}
+
+// DeleteSCIMTokensOfAccount revokes the SCIM token of an account
+func DeleteSCIMTokensOfAccount(tx *storage.Connection, accountID uuid.UUID) error {
+	tableName := SCIMToken{}.TableName()
+	if err := tx.RawQuery("DELETE FROM "+tableName+" WHERE account_id = ?", accountID).Exec(); err != nil {
+		return errors.Wrap(err, "error deleting SCIM tokens")
+	}
+	return nil
+}
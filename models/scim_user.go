@@ -0,0 +1,173 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/delivc/team/storage/namespace"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// SCIMUser is a user provisioned into an account by the IdP of the account.
+// Identity users are linked by their confirmed email on their next authentication,
+// from then on the membership follows the provisioned user
+type SCIMUser struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	AccountID   uuid.UUID  `json:"-" db:"account_id"`
+	UserID      *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	RoleID      uuid.UUID  `json:"role_id" db:"role_id"`
+	ExternalID  string     `json:"external_id,omitempty" db:"external_id"`
+	UserName    string     `json:"user_name" db:"user_name"`
+	DisplayName string     `json:"display_name,omitempty" db:"display_name"`
+	Active      bool       `json:"active" db:"active"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TableName returns the given tablename of the model
+func (SCIMUser) TableName() string {
+	tableName := "scim_users"
+
+	if namespace.GetNamespace() != "" {
+		return namespace.GetNamespace() + "_" + tableName
+	}
+
+	return tableName
+}
+
+// NewSCIMUser creates a new active SCIMUser
+// does not create!!! the user in the database
+func NewSCIMUser(accountID, roleID uuid.UUID, userName string) (*SCIMUser, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error generating unique id")
+	}
+
+	user := &SCIMUser{
+		ID:        id,
+		AccountID: accountID,
+		RoleID:    roleID,
+		UserName:  strings.ToLower(strings.TrimSpace(userName)),
+		Active:    true,
+	}
+	return user, nil
+}
+
+// Link connects the provisioned user with an identity user and syncs the membership
+func (u *SCIMUser) Link(tx *storage.Connection, userID uuid.UUID) error {
+	u.UserID = &userID
+	if err := tx.UpdateOnly(u, "user_id", "updated_at"); err != nil {
+		return errors.Wrap(err, "error linking provisioned user")
+	}
+	return u.SyncMembership(tx)
+}
+
+// SyncMembership makes the membership of a linked user match the provisioned user,
+// active users are members with the role of their group, inactive users are removed.
+// Users are only invited if the domain of their user name is not verified by the account
+func (u *SCIMUser) SyncMembership(tx *storage.Connection) error {
+	if u.UserID == nil {
+		return nil
+	}
+
+	if !u.Active {
+		return DeleteAccountUser(tx, u.AccountID, *u.UserID)
+	}
+
+	member := &AccountUser{}
+	err := tx.Q().Where("account_id = ? and user_id = ?", u.AccountID, *u.UserID).First(member)
+	if err != nil {
+		if errors.Cause(err) != sql.ErrNoRows {
+			return errors.Wrap(err, "error finding account user")
+		}
+		verified, err := IsEmailDomainVerified(tx, u.AccountID, u.UserName)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			_, err = InviteUserToAccount(tx, u.AccountID, *u.UserID, u.RoleID, uuid.Nil)
+			return err
+		}
+		now := time.Now()
+		member = &AccountUser{
+			AccountID:   u.AccountID,
+			UserID:      *u.UserID,
+			RoleID:      u.RoleID,
+			ConfirmedAt: &now,
+		}
+		if err = tx.Create(member); err != nil {
+			return errors.Wrap(err, "Error attaching user to account")
+		}
+		return nil
+	}
+
+	// the IdP decides about access, memberships do not run out on their own
+	member.RoleID = u.RoleID
+	member.ExpiresAt = nil
+	return tx.UpdateOnly(member, "role_id", "expires_at", "updated_at")
+}
+
+// UpdateRole moves the provisioned user into another group
+func (u *SCIMUser) UpdateRole(tx *storage.Connection, roleID uuid.UUID) error {
+	u.RoleID = roleID
+	if err := tx.UpdateOnly(u, "role_id", "updated_at"); err != nil {
+		return errors.Wrap(err, "error updating role of provisioned user")
+	}
+	return u.SyncMembership(tx)
+}
+
+// FindSCIMUsers returns the provisioned users of an account
+func FindSCIMUsers(tx *storage.Connection, accountID uuid.UUID) ([]*SCIMUser, error) {
+	obj := []*SCIMUser{}
+	if err := tx.Q().Where("account_id = ?", accountID).Order("created_at ASC").All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding provisioned users")
+	}
+	return obj, nil
+}
+
+// FindSCIMUserByAccountAndID returns a single provisioned user of an account
+func FindSCIMUserByAccountAndID(tx *storage.Connection, accountID, id uuid.UUID) (*SCIMUser, error) {
+	obj := &SCIMUser{}
+	if err := tx.Q().Where("account_id = ? and id = ?", accountID, id).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SCIMUserNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding provisioned user")
+	}
+	return obj, nil
+}
+
+// FindSCIMUserByUserName returns the provisioned user of an account with the given user name
+func FindSCIMUserByUserName(tx *storage.Connection, accountID uuid.UUID, userName string) (*SCIMUser, error) {
+	obj := &SCIMUser{}
+	if err := tx.Q().Where("account_id = ? and user_name = ?", accountID, strings.ToLower(strings.TrimSpace(userName))).First(obj); err != nil {
+		if errors.Cause(err) == sql.ErrNoRows {
+			return nil, SCIMUserNotFoundError{}
+		}
+		return nil, errors.Wrap(err, "error finding provisioned user")
+	}
+	return obj, nil
+}
+
+// FindUnlinkedSCIMUsers returns the active provisioned users of all accounts
+// with the given user name which are not linked to an identity user yet
+func FindUnlinkedSCIMUsers(tx *storage.Connection, userName string) ([]*SCIMUser, error) {
+	obj := []*SCIMUser{}
+	if err := tx.Q().Where("user_name = ? and user_id IS NULL and active = ?", strings.ToLower(userName), true).All(&obj); err != nil {
+		return nil, errors.Wrap(err, "error finding provisioned users")
+	}
+	return obj, nil
+}
+
+// DeleteSCIMUser removes a provisioned user and the membership of its identity user
+func DeleteSCIMUser(tx *storage.Connection, u *SCIMUser) error {
+	if u.UserID != nil {
+		if err := DeleteAccountUser(tx, u.AccountID, *u.UserID); err != nil {
+			return err
+		}
+	}
+	return tx.Destroy(u)
+}
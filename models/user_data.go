@@ -0,0 +1,221 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// UserData is everything Team stores about a user,
+// the answer to a subject access request
+type UserData struct {
+	UserID           uuid.UUID             `json:"user_id"`
+	ExportedAt       time.Time             `json:"exported_at"`
+	OwnedAccounts    []UserAccount         `json:"owned_accounts"`
+	Memberships      []UserMembership      `json:"memberships"`
+	InvitesSent      []UserMembership      `json:"invites_sent"`
+	RoleAssignments  []UserRoleAssignment  `json:"role_assignments"`
+	AccessRequests   []*AccessRequest      `json:"access_requests"`
+	RequestsDecided  []*AccessRequest      `json:"access_requests_decided"`
+	ProvisionedUsers []UserProvisionedUser `json:"provisioned_users"`
+	SCIMTokens       []UserSCIMToken       `json:"scim_tokens_created"`
+	AuditLogEntries  []*AuditLogEntry      `json:"audit_log_entries"`
+}
+
+// UserAccount is an account owned by the user
+type UserAccount struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// UserMembership is a membership of the user or an invitation it sent
+type UserMembership struct {
+	AccountID uuid.UUID `json:"account_id"`
+	*AccountUser
+}
+
+// UserRoleAssignment is a resource scoped role of the user
+type UserRoleAssignment struct {
+	AccountID uuid.UUID `json:"account_id"`
+	*RoleAssignment
+}
+
+// UserProvisionedUser is a SCIM user linked to the user
+type UserProvisionedUser struct {
+	AccountID uuid.UUID `json:"account_id"`
+	*SCIMUser
+}
+
+// UserSCIMToken is a SCIM token the user created
+type UserSCIMToken struct {
+	AccountID uuid.UUID `json:"account_id"`
+	*SCIMToken
+}
+
+// ExportUserData collects every row which refers to the user
+func ExportUserData(tx *storage.Connection, userID uuid.UUID) (*UserData, error) {
+	data := &UserData{
+		UserID:           userID,
+		ExportedAt:       time.Now().UTC(),
+		OwnedAccounts:    []UserAccount{},
+		Memberships:      []UserMembership{},
+		InvitesSent:      []UserMembership{},
+		RoleAssignments:  []UserRoleAssignment{},
+		AccessRequests:   []*AccessRequest{},
+		RequestsDecided:  []*AccessRequest{},
+		ProvisionedUsers: []UserProvisionedUser{},
+		SCIMTokens:       []UserSCIMToken{},
+		AuditLogEntries:  []*AuditLogEntry{},
+	}
+
+	owned := []*Account{}
+	if err := tx.Q().Where("JSON_SEARCH(raw_owner_ids, 'one', ?) IS NOT NULL", userID.String()).All(&owned); err != nil {
+		return nil, errors.Wrap(err, "error finding owned accounts")
+	}
+	for _, account := range owned {
+		data.OwnedAccounts = append(data.OwnedAccounts, UserAccount{ID: account.ID, Name: account.Name})
+	}
+
+	memberships := []*AccountUser{}
+	if err := tx.Q().Where("user_id = ?", userID).All(&memberships); err != nil {
+		return nil, errors.Wrap(err, "error finding memberships")
+	}
+	for _, member := range memberships {
+		data.Memberships = append(data.Memberships, UserMembership{AccountID: member.AccountID, AccountUser: member})
+	}
+
+	invites := []*AccountUser{}
+	if err := tx.Q().Where("invited_by = ?", userID).All(&invites); err != nil {
+		return nil, errors.Wrap(err, "error finding invites")
+	}
+	for _, member := range invites {
+		data.InvitesSent = append(data.InvitesSent, UserMembership{AccountID: member.AccountID, AccountUser: member})
+	}
+
+	assignments := []*RoleAssignment{}
+	if err := tx.Q().Where("user_id = ?", userID).All(&assignments); err != nil {
+		return nil, errors.Wrap(err, "error finding role assignments")
+	}
+	for _, assignment := range assignments {
+		data.RoleAssignments = append(data.RoleAssignments, UserRoleAssignment{AccountID: assignment.AccountID, RoleAssignment: assignment})
+	}
+
+	if err := tx.Q().Where("user_id = ?", userID).All(&data.AccessRequests); err != nil {
+		return nil, errors.Wrap(err, "error finding access requests")
+	}
+	if err := tx.Q().Where("decided_by = ?", userID).All(&data.RequestsDecided); err != nil {
+		return nil, errors.Wrap(err, "error finding decided access requests")
+	}
+
+	provisioned := []*SCIMUser{}
+	if err := tx.Q().Where("user_id = ?", userID).All(&provisioned); err != nil {
+		return nil, errors.Wrap(err, "error finding provisioned users")
+	}
+	for _, user := range provisioned {
+		data.ProvisionedUsers = append(data.ProvisionedUsers, UserProvisionedUser{AccountID: user.AccountID, SCIMUser: user})
+	}
+
+	tokens := []*SCIMToken{}
+	if err := tx.Q().Where("created_by = ?", userID).All(&tokens); err != nil {
+		return nil, errors.Wrap(err, "error finding SCIM tokens")
+	}
+	for _, token := range tokens {
+		data.SCIMTokens = append(data.SCIMTokens, UserSCIMToken{AccountID: token.AccountID, SCIMToken: token})
+	}
+
+	if err := tx.Q().Where("actor_id = ? OR subject_id = ? OR JSON_SEARCH(payload, 'one', ?) IS NOT NULL", userID, userID, userID.String()).Order("created_at ASC").All(&data.AuditLogEntries); err != nil {
+		return nil, errors.Wrap(err, "error finding audit log entries")
+	}
+	return data, nil
+}
+
+// EraseUserData anonymizes what is left of a user after RemoveUser:
+// access requests are deleted and references in other rows are replaced by uuid.Nil.
+// Provisioned users stay as they belong to the identity provider of the account,
+// EraseSCIMUsers anonymizes them
+func EraseUserData(tx *storage.Connection, userID uuid.UUID) error {
+	anonymous := uuid.Nil.String()
+	auditTable := AuditLogEntry{}.TableName()
+	statements := []struct {
+		query string
+		args  []interface{}
+		what  string
+	}{
+		{"DELETE FROM " + AccessRequest{}.TableName() + " WHERE user_id = ?", []interface{}{userID}, "access requests"},
+		{"UPDATE " + AccessRequest{}.TableName() + " SET decided_by = NULL WHERE decided_by = ?", []interface{}{userID}, "decided access requests"},
+		{"UPDATE " + AccountUser{}.TableName() + " SET invited_by = ? WHERE invited_by = ?", []interface{}{anonymous, userID}, "invites"},
+		{"UPDATE " + SCIMToken{}.TableName() + " SET created_by = ? WHERE created_by = ?", []interface{}{anonymous, userID}, "SCIM tokens"},
+		{"UPDATE " + auditTable + " SET actor_id = ? WHERE actor_id = ?", []interface{}{anonymous, userID}, "audit log actors"},
+		{"UPDATE " + auditTable + " SET subject_id = ? WHERE subject_id = ?", []interface{}{anonymous, userID}, "audit log subjects"},
+		{"UPDATE " + auditTable + " SET payload = CAST(REPLACE(CAST(payload AS CHAR), ?, ?) AS JSON) WHERE JSON_SEARCH(payload, 'one', ?) IS NOT NULL", []interface{}{userID.String(), anonymous, userID.String()}, "audit log payloads"},
+	}
+	for _, statement := range statements {
+		if err := tx.RawQuery(statement.query, statement.args...).Exec(); err != nil {
+			return errors.Wrap(err, "error erasing "+statement.what)
+		}
+	}
+	return nil
+}
+
+// erasedUserName replaces the user names of erased users in the audit log
+const erasedUserName = "erased"
+
+// provisionedUserNames returns the user names, the emails, the user was provisioned with,
+// from its provisioned users and the audit log entries of deprovisioned ones
+func provisionedUserNames(provisioned []*SCIMUser, entries []*AuditLogEntry) []string {
+	names := map[string]bool{}
+	for _, user := range provisioned {
+		names[user.UserName] = true
+	}
+	for _, entry := range entries {
+		if name, ok := entry.Payload["user_name"].(string); ok && name != "" {
+			names[name] = true
+		}
+	}
+	result := []string{}
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// EraseSCIMUsers anonymizes and deactivates the users provisioned for the identity user,
+// so the IdP has to provision it again, and scrubs their user names from the audit log.
+// It has to run before RemoveUser unlinks the provisioned users
+func EraseSCIMUsers(tx *storage.Connection, userID uuid.UUID) error {
+	provisioned := []*SCIMUser{}
+	if err := tx.Q().Where("user_id = ?", userID).All(&provisioned); err != nil {
+		return errors.Wrap(err, "error finding provisioned users")
+	}
+	entries := []*AuditLogEntry{}
+	if err := tx.Q().Where("subject_id = ?", userID).All(&entries); err != nil {
+		return errors.Wrap(err, "error finding audit log entries")
+	}
+	names := provisionedUserNames(provisioned, entries)
+
+	for _, user := range provisioned {
+		user.UserName = erasedUserName + "-" + user.ID.String()
+		user.DisplayName = ""
+		user.Active = false
+		if err := tx.UpdateOnly(user, "user_name", "display_name", "active", "updated_at"); err != nil {
+			return errors.Wrap(err, "error erasing provisioned user")
+		}
+	}
+
+	erased, _ := json.Marshal(erasedUserName)
+	for _, name := range names {
+		quoted, err := json.Marshal(name)
+		if err != nil {
+			return errors.Wrap(err, "error encoding user name")
+		}
+		if err := tx.RawQuery("UPDATE "+AuditLogEntry{}.TableName()+" SET payload = CAST(REPLACE(CAST(payload AS CHAR), ?, ?) AS JSON) WHERE JSON_SEARCH(payload, 'one', ?) IS NOT NULL", string(quoted), string(erased), name).Exec(); err != nil {
+			return errors.Wrap(err, "error erasing audit log user names")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserMembershipJSON(t *testing.T) {
+	member := &AccountUser{AccountID: uuid.Must(uuid.NewV4()), UserID: uuid.Must(uuid.NewV4()), RoleID: uuid.Must(uuid.NewV4())}
+
+	data, err := json.Marshal(UserMembership{AccountID: member.AccountID, AccountUser: member})
+	require.NoError(t, err)
+
+	decoded := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, member.AccountID.String(), decoded["account_id"])
+	assert.Equal(t, member.UserID.String(), decoded["user_id"])
+	assert.Equal(t, member.RoleID.String(), decoded["role_id"])
+}
+
+func TestProvisionedUserNames(t *testing.T) {
+	provisioned := []*SCIMUser{{UserName: "jane@example.com"}}
+	entries := []*AuditLogEntry{
+		{Action: SCIMUserDeprovisionedAction, Payload: JSONMap{"user_name": "jane@acme.io"}},
+		{Action: SCIMUserLinkedAction, Payload: JSONMap{"user_name": "jane@example.com"}},
+		{Action: MemberLeftAction, Payload: JSONMap{"was_member": true}},
+	}
+
+	assert.Equal(t, []string{"jane@acme.io", "jane@example.com"}, provisionedUserNames(provisioned, entries))
+}
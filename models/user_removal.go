@@ -0,0 +1,189 @@
+package models
+
+import (
+	"time"
+
+	"github.com/delivc/team/storage"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+)
+
+// AccountRemoval describes how an account changed when a user was removed from Team
+type AccountRemoval struct {
+	AccountID uuid.UUID `json:"account_id"`
+	WasMember bool      `json:"was_member"`
+	WasOwner  bool      `json:"was_owner"`
+	// NewOwnerID is the member which took over an account of a sole owner
+	NewOwnerID *uuid.UUID `json:"new_owner_id,omitempty"`
+	Orphaned   bool       `json:"orphaned"`
+}
+
+// successor picks the member which takes over an account without owners,
+// the longest standing member with a role of the Admin template
+func successor(tx *storage.Connection, account *Account, userID uuid.UUID) (*uuid.UUID, error) {
+	members, err := FindAccountUsers(tx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+	roles := []*Role{}
+	if err := tx.Q().Where("account_id = ? and template = ?", account.ID, AdminTemplate).All(&roles); err != nil {
+		return nil, errors.Wrap(err, "error finding admin roles")
+	}
+	adminRoles := map[uuid.UUID]bool{}
+	for _, role := range roles {
+		adminRoles[role.ID] = true
+	}
+	return pickSuccessor(members, adminRoles, userID), nil
+}
+
+// pickSuccessor returns the first member holding one of the admin roles,
+// invitations which are not accepted yet never take over
+func pickSuccessor(members []*AccountUser, adminRoles map[uuid.UUID]bool, userID uuid.UUID) *uuid.UUID {
+	for _, member := range members {
+		if member.UserID != userID && !member.IsPending() && adminRoles[member.RoleID] {
+			id := member.UserID
+			return &id
+		}
+	}
+	return nil
+}
+
+// RemoveOwner takes the ownership of an account away from a user,
+// a sole owner is replaced by its successor or the account is flagged as orphaned
+func RemoveOwner(tx *storage.Connection, account *Account, userID uuid.UUID) (*AccountRemoval, error) {
+	removal := &AccountRemoval{AccountID: account.ID}
+
+	owners := []uuid.UUID{}
+	for _, owner := range account.Owners() {
+		if owner == userID {
+			removal.WasOwner = true
+			continue
+		}
+		owners = append(owners, owner)
+	}
+	if !removal.WasOwner {
+		return removal, nil
+	}
+
+	if len(owners) == 0 {
+		next, err := successor(tx, account, userID)
+		if err != nil {
+			return nil, err
+		}
+		if next != nil {
+			owners = append(owners, *next)
+			removal.NewOwnerID = next
+		} else {
+			now := time.Now()
+			account.OrphanedAt = &now
+			removal.Orphaned = true
+		}
+	}
+
+	account.SetOwners(owners)
+	if err := tx.UpdateOnly(account, "raw_owner_ids", "orphaned_at", "updated_at"); err != nil {
+		return nil, errors.Wrap(err, "error updating owners of account")
+	}
+	return removal, nil
+}
+
+// RemoveUser removes everything Team knows about a user: ownerships, memberships,
+// role assignments, pending access requests and the links of provisioned users,
+// which are deactivated until the IdP activates them again
+func RemoveUser(tx *storage.Connection, userID uuid.UUID) ([]*AccountRemoval, error) {
+	removals := map[uuid.UUID]*AccountRemoval{}
+	order := []uuid.UUID{}
+	track := func(removal *AccountRemoval) *AccountRemoval {
+		if existing, ok := removals[removal.AccountID]; ok {
+			return existing
+		}
+		removals[removal.AccountID] = removal
+		order = append(order, removal.AccountID)
+		return removal
+	}
+
+	owned := []*Account{}
+	// successors are looked up when needed, the members are not loaded here
+	if err := tx.Q().Where("JSON_SEARCH(raw_owner_ids, 'one', ?) IS NOT NULL", userID.String()).All(&owned); err != nil {
+		return nil, errors.Wrap(err, "error finding owned accounts")
+	}
+	for _, account := range owned {
+		// owners leaving meanwhile must not be missed, read the owners again under the lock
+		if err := LockAccount(tx, account.ID); err != nil {
+			return nil, err
+		}
+		if err := tx.Find(account, account.ID); err != nil {
+			return nil, errors.Wrap(err, "error finding owned account")
+		}
+		removal, err := RemoveOwner(tx, account, userID)
+		if err != nil {
+			return nil, err
+		}
+		track(removal)
+	}
+
+	memberships := []*AccountUser{}
+	if err := tx.Q().Where("user_id = ?", userID).All(&memberships); err != nil {
+		return nil, errors.Wrap(err, "error finding memberships")
+	}
+	for _, membership := range memberships {
+		if err := DeleteAccountUser(tx, membership.AccountID, userID); err != nil {
+			return nil, err
+		}
+		track(&AccountRemoval{AccountID: membership.AccountID}).WasMember = true
+	}
+
+	statements := []struct {
+		query string
+		what  string
+	}{
+		{"DELETE FROM " + RoleAssignment{}.TableName() + " WHERE user_id = ?", "role assignments"},
+		{"DELETE FROM " + AccessRequest{}.TableName() + " WHERE user_id = ? AND status = '" + AccessRequestPending + "'", "access requests"},
+		// deactivated, the user would be linked again on its next request otherwise
+		{"UPDATE " + SCIMUser{}.TableName() + " SET user_id = NULL, active = 0 WHERE user_id = ?", "provisioned users"},
+	}
+	for _, statement := range statements {
+		if err := tx.RawQuery(statement.query, userID).Exec(); err != nil {
+			return nil, errors.Wrap(err, "error removing "+statement.what)
+		}
+	}
+
+	result := []*AccountRemoval{}
+	for _, accountID := range order {
+		result = append(result, removals[accountID])
+	}
+	return result, nil
+}
+
+// LeaveAccount removes the membership and the ownership of a user from an account,
+// the caller has to make sure that the account keeps at least one owner
+func LeaveAccount(tx *storage.Connection, account *Account, userID uuid.UUID) (*AccountRemoval, error) {
+	removal := &AccountRemoval{AccountID: account.ID}
+
+	owners := []uuid.UUID{}
+	for _, owner := range account.Owners() {
+		if owner == userID {
+			removal.WasOwner = true
+			continue
+		}
+		owners = append(owners, owner)
+	}
+	if removal.WasOwner {
+		account.SetOwners(owners)
+		if err := tx.UpdateOnly(account, "raw_owner_ids", "updated_at"); err != nil {
+			return nil, errors.Wrap(err, "error updating owners of account")
+		}
+	}
+
+	member, err := HasAccountUser(tx, account.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		if err := DeleteAccountUser(tx, account.ID, userID); err != nil {
+			return nil, err
+		}
+		removal.WasMember = true
+	}
+	return removal, nil
+}